@@ -0,0 +1,228 @@
+package chamqp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// BlockedError is returned by publishes while the broker blocks the
+// connection, for example because of a memory or disk alarm.
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return "chamqp: connection blocked by broker: " + e.Reason
+}
+
+type blockedMode int
+
+const (
+	blockedPassThrough blockedMode = iota
+	blockedFailFast
+	blockedWait
+	blockedBuffer
+)
+
+// WithBlockedFailFast makes publishes return a *BlockedError right away while
+// the connection is blocked.
+func WithBlockedFailFast() Option {
+	return func(o *options) {
+		o.blockedMode = blockedFailFast
+	}
+}
+
+// WithBlockedWait makes publishes wait until the connection is unblocked.
+// They return a *BlockedError after timeout, or the context error once the
+// publish context is done. A timeout of 0 only respects the context.
+func WithBlockedWait(timeout time.Duration) Option {
+	return func(o *options) {
+		o.blockedMode = blockedWait
+		o.blockedTimeout = timeout
+	}
+}
+
+// WithBlockedBuffer makes publishes queue up to size messages per channel
+// while the connection is blocked. Queued messages are sent once the
+// connection is unblocked or re-established, ahead of later publishes;
+// publishes beyond size return a *BlockedError.
+func WithBlockedBuffer(size int) Option {
+	return func(o *options) {
+		o.blockedMode = blockedBuffer
+		o.blockedBufferSize = size
+	}
+}
+
+// Blocked returns whether the broker currently blocks the connection and why.
+func (c *Connection) Blocked() amqp.Blocking {
+	c.blocked.mu.Lock()
+	defer c.blocked.mu.Unlock()
+
+	return c.blocked.current
+}
+
+// NotifyBlocked registers a listener for connection.blocked and
+// connection.unblocked notifications. It survives reconnects. Sends never
+// block, so use a buffered channel.
+func (c *Connection) NotifyBlocked(receiver chan amqp.Blocking) chan amqp.Blocking {
+	c.blocked.subs.add(receiver, DropNewest)
+
+	return receiver
+}
+
+func (c *Connection) watchBlocked(notifyBlocked <-chan amqp.Blocking) {
+	for blocking := range notifyBlocked {
+		if blocking.Active {
+			c.opts.logger.Warn("connection blocked by broker", "reason", blocking.Reason)
+		} else {
+			c.opts.logger.Info("connection unblocked by broker")
+		}
+		c.blocked.set(blocking)
+	}
+}
+
+// blockedState has its own lock, since notifications arrive from the amqp
+// reader goroutine while c.mu may be held for a broker round trip.
+type blockedState struct {
+	mu        sync.Mutex
+	current   amqp.Blocking
+	unblocked chan struct{}
+	subs      subscribers[amqp.Blocking]
+}
+
+func newBlockedState() blockedState {
+	unblocked := make(chan struct{})
+	close(unblocked)
+	return blockedState{unblocked: unblocked}
+}
+
+func (b *blockedState) set(blocking amqp.Blocking) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if blocking.Active == b.current.Active {
+		b.current = blocking
+		return
+	}
+	if blocking.Active {
+		b.unblocked = make(chan struct{})
+	} else {
+		close(b.unblocked)
+	}
+	b.current = blocking
+	b.subs.send(blocking)
+}
+
+// state returns the current blocking and a channel closed once it ends.
+func (b *blockedState) state() (amqp.Blocking, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.current, b.unblocked
+}
+
+type pendingPublish struct {
+	exchange, key        string
+	mandatory, immediate bool
+	msg                  amqp.Publishing
+	// sent, if set, receives the confirmation once the buffered publish was
+	// sent, or nil if it failed or was dropped. It must not block.
+	sent chan<- *amqp.DeferredConfirmation
+}
+
+// done reports the outcome of a buffered publish to whoever waits for it.
+func (p pendingPublish) done(confirmation *amqp.DeferredConfirmation) {
+	if p.sent != nil {
+		p.sent <- confirmation
+	}
+}
+
+// admitPublish applies the blocked policy of the parent connection. It
+// returns true if the publish was buffered and must not be sent now.
+func (ch *Channel) admitPublish(ctx context.Context, p pendingPublish) (bool, error) {
+	if ch.conn == nil {
+		return false, nil
+	}
+	blocking, unblocked := ch.conn.blocked.state()
+	if !blocking.Active {
+		return false, nil
+	}
+
+	switch ch.conn.opts.blockedMode {
+	case blockedFailFast:
+		return false, &BlockedError{blocking.Reason}
+	case blockedWait:
+		var timeout <-chan time.Time
+		if ch.conn.opts.blockedTimeout > 0 {
+			timer := time.NewTimer(ch.conn.opts.blockedTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-unblocked:
+			return false, nil
+		case <-timeout:
+			return false, &BlockedError{blocking.Reason}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	case blockedBuffer:
+		return true, ch.bufferPublish(p, blocking, unblocked)
+	}
+	return false, nil
+}
+
+func (ch *Channel) bufferPublish(p pendingPublish, blocking amqp.Blocking, unblocked <-chan struct{}) error {
+	ch.pendingMu.Lock()
+	defer ch.pendingMu.Unlock()
+
+	if len(ch.pending) >= ch.conn.opts.blockedBufferSize {
+		return &BlockedError{blocking.Reason}
+	}
+	ch.pending = append(ch.pending, p)
+	if len(ch.pending) == 1 {
+		go func() {
+			<-unblocked
+			ch.flushPending()
+		}()
+	}
+	return nil
+}
+
+// flushPending sends publishes buffered while the connection was blocked.
+// Without an open channel they are kept until the channel is restored.
+func (ch *Channel) flushPending() {
+	ch.publishMu.Lock()
+	defer ch.publishMu.Unlock()
+
+	ch.sendPending()
+}
+
+// sendPending is flushPending with publishMu held. Publishes call it before
+// sending, so they cannot overtake the buffered ones once unblocked.
+func (ch *Channel) sendPending() {
+	channel := ch.ch.Load()
+	ch.pendingMu.Lock()
+	if channel == nil || len(ch.pending) == 0 {
+		ch.pendingMu.Unlock()
+		return
+	}
+	pending := ch.pending
+	ch.pending = nil
+	ch.pendingMu.Unlock()
+
+	for _, p := range pending {
+		labels := map[string]string{"exchange": p.exchange}
+		confirmation, err := ch.send(context.Background(), channel, p)
+		p.done(confirmation)
+		if err != nil {
+			ch.meter().AddCounter(MetricPublishErrors, 1, labels)
+			ch.Logger().Error("publishing buffered message failed", "exchange", p.exchange, "key", p.key, "error", err)
+			continue
+		}
+		ch.meter().AddCounter(MetricPublishes, 1, labels)
+	}
+}
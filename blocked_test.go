@@ -0,0 +1,97 @@
+package chamqp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func blockedChannel(opts ...Option) *Channel {
+	conn := newConnection(opts)
+	conn.blocked.set(amqp.Blocking{Active: true, Reason: "low on memory"})
+	return &Channel{conn: conn}
+}
+
+func TestBlockedPolicies(t *testing.T) {
+	t.Run("pass through by default", func(t *testing.T) {
+		buffered, err := blockedChannel().admitPublish(context.Background(), pendingPublish{})
+		assert.False(t, buffered)
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		_, err := blockedChannel(WithBlockedFailFast()).admitPublish(context.Background(), pendingPublish{})
+		var blockedErr *BlockedError
+		assert.ErrorAs(t, err, &blockedErr)
+		assert.Equal(t, "low on memory", blockedErr.Reason)
+	})
+
+	t.Run("wait times out", func(t *testing.T) {
+		_, err := blockedChannel(WithBlockedWait(time.Millisecond)).admitPublish(context.Background(), pendingPublish{})
+		assert.IsType(t, &BlockedError{}, err)
+	})
+
+	t.Run("wait returns once unblocked", func(t *testing.T) {
+		ch := blockedChannel(WithBlockedWait(0))
+		go ch.conn.blocked.set(amqp.Blocking{Active: false})
+
+		buffered, err := ch.admitPublish(context.Background(), pendingPublish{})
+		assert.False(t, buffered)
+		assert.NoError(t, err)
+	})
+
+	t.Run("buffer keeps messages up to its size", func(t *testing.T) {
+		ch := blockedChannel(WithBlockedBuffer(1))
+
+		buffered, err := ch.admitPublish(context.Background(), pendingPublish{exchange: "events"})
+		assert.True(t, buffered)
+		assert.NoError(t, err)
+
+		_, err = ch.admitPublish(context.Background(), pendingPublish{exchange: "events"})
+		assert.IsType(t, &BlockedError{}, err)
+
+		ch.flushPending()
+		ch.pendingMu.Lock()
+		assert.Len(t, ch.pending, 1, "kept until a channel is available")
+		ch.pendingMu.Unlock()
+	})
+}
+
+func TestBlockedBuffer(t *testing.T) {
+	t.Run("buffered publishes go first once unblocked", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}), WithBlockedBuffer(10))
+		defer conn.Close()
+		ch := conn.Channel()
+		waitReady(t, conn)
+
+		conn.blocked.set(amqp.Blocking{Active: true, Reason: "low on memory"})
+		for _, key := range []string{"first", "second"} {
+			assert.NoError(t, ch.Publish("events", key, false, false, amqp.Publishing{}))
+		}
+		conn.blocked.set(amqp.Blocking{Active: false})
+		assert.NoError(t, ch.Publish("events", "third", false, false, amqp.Publishing{}))
+
+		assert.Eventually(t, func() bool { return len(broker.publishedKeys()) == 3 }, time.Second, time.Millisecond)
+		assert.Equal(t, []string{"first", "second", "third"}, broker.publishedKeys())
+	})
+}
+
+func TestNotifyBlocked(t *testing.T) {
+	t.Run("receivers get transitions only", func(t *testing.T) {
+		conn := newConnection(nil)
+		receiver := conn.NotifyBlocked(make(chan amqp.Blocking, 4))
+
+		conn.blocked.set(amqp.Blocking{Active: true, Reason: "disk"})
+		conn.blocked.set(amqp.Blocking{Active: true, Reason: "disk"})
+		conn.blocked.set(amqp.Blocking{Active: false})
+
+		assert.Equal(t, amqp.Blocking{Active: true, Reason: "disk"}, <-receiver)
+		assert.Equal(t, amqp.Blocking{Active: false}, <-receiver)
+		assert.Len(t, receiver, 0)
+		assert.False(t, conn.Blocked().Active)
+	})
+}
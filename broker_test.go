@@ -0,0 +1,343 @@
+package chamqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeBroker speaks just enough AMQP 0-9-1 to open connections and
+// channels, acknowledge topology methods and swallow publishes. Declaring a
+// queue named missing* fails with a channel exception, mandatory publishes to
+// exchanges named unroutable* are returned without their body, publishes to
+// exchanges named missing* fail with a channel exception. Messages reach
+// consumers only through deliver. Replies to methods sent with no-wait are
+// not suppressed, so tests must not use it.
+type fakeBroker struct {
+	listener     net.Listener
+	mu           sync.Mutex
+	conns        []net.Conn
+	consumers    []fakeConsumer
+	publishes    atomic.Int64
+	acks         atomic.Int64
+	holdConfirms atomic.Bool
+	deliveryTags atomic.Uint64
+	closes       atomic.Int64 // connection.close received from clients
+	received     []string     // topology methods in the order they arrived
+	secrets      []string     // sent with connection.update-secret
+	keys         []string     // routing keys of publishes in the order they arrived
+}
+
+// topologyMethods names the methods recorded in received.
+var topologyMethods = map[uint16]string{
+	40<<8 | 10: "exchange.declare",
+	40<<8 | 20: "exchange.delete",
+	40<<8 | 30: "exchange.bind",
+	40<<8 | 40: "exchange.unbind",
+	50<<8 | 10: "queue.declare",
+	50<<8 | 20: "queue.bind",
+	50<<8 | 30: "queue.purge",
+	50<<8 | 40: "queue.delete",
+	50<<8 | 50: "queue.unbind",
+	60<<8 | 10: "basic.qos",
+	60<<8 | 20: "basic.consume",
+}
+
+type fakeConsumer struct {
+	w       *frameWriter
+	channel uint16
+	queue   string
+	tag     string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{listener: listener}
+	t.Cleanup(func() {
+		listener.Close()
+		b.dropConnections()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "amqp://guest:guest@" + b.listener.Addr().String() + "/"
+}
+
+// dropConnections simulates a broker restart by closing all sockets.
+func (b *fakeBroker) dropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+	b.consumers = nil
+}
+
+// deliver sends body to every consumer of queue and returns how many
+// received it.
+func (b *fakeBroker) deliver(queue string, body []byte) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delivered := 0
+	for _, consumer := range b.consumers {
+		if consumer.queue != queue {
+			continue
+		}
+		header := binary.BigEndian.AppendUint16(nil, 60)
+		header = binary.BigEndian.AppendUint16(header, 0)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+		header = binary.BigEndian.AppendUint16(header, 0)
+		consumer.w.write(
+			methodFrame(consumer.channel, 60, 60, consumer.tag, b.deliveryTags.Add(1), uint8(0), "", queue),
+			frame(2, consumer.channel, header),
+			frame(3, consumer.channel, body),
+		)
+		delivered++
+	}
+	return delivered
+}
+
+// removeConsumer removes the consumer tag, or all consumers of the channel
+// if tag is empty.
+func (b *fakeBroker) removeConsumer(w *frameWriter, channel uint16, tag string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var kept []fakeConsumer
+	for _, consumer := range b.consumers {
+		if consumer.w != w || consumer.channel != channel || (tag != "" && consumer.tag != tag) {
+			kept = append(kept, consumer)
+		}
+	}
+	b.consumers = kept
+}
+
+// methods returns the topology methods received so far.
+func (b *fakeBroker) methods() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.received...)
+}
+
+// updatedSecrets returns the secrets received with connection.update-secret.
+// publishedKeys returns the routing keys of the publishes received so far.
+func (b *fakeBroker) publishedKeys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.keys...)
+}
+
+func (b *fakeBroker) updatedSecrets() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.secrets...)
+}
+
+type frameWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (f *frameWriter) method(channel uint16, class, method uint16, args ...any) {
+	f.write(methodFrame(channel, class, method, args...))
+}
+
+// write sends frames without interleaving frames of other goroutines.
+func (f *frameWriter) write(frames ...[]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, frame := range frames {
+		f.w.Write(frame)
+	}
+}
+
+func frame(kind byte, channel uint16, payload []byte) []byte {
+	frame := []byte{kind}
+	frame = binary.BigEndian.AppendUint16(frame, channel)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	return append(frame, 0xce)
+}
+
+func methodFrame(channel uint16, class, method uint16, args ...any) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, class)
+	payload = binary.BigEndian.AppendUint16(payload, method)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint8:
+			payload = append(payload, v)
+		case uint16:
+			payload = binary.BigEndian.AppendUint16(payload, v)
+		case uint32:
+			payload = binary.BigEndian.AppendUint32(payload, v)
+		case uint64:
+			payload = binary.BigEndian.AppendUint64(payload, v)
+		case string: // shortstr
+			payload = append(payload, byte(len(v)))
+			payload = append(payload, v...)
+		case []byte: // longstr, also used for empty tables
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(v)))
+			payload = append(payload, v...)
+		}
+	}
+
+	return frame(1, channel, payload)
+}
+
+func shortString(payload []byte) string {
+	if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+		return ""
+	}
+	return string(payload[1 : 1+payload[0]])
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := &frameWriter{w: conn}
+	if _, err := io.ReadFull(r, make([]byte, 8)); err != nil {
+		return
+	}
+	w.method(0, 10, 10, uint8(0), uint8(9), []byte{}, []byte("PLAIN"), []byte("en_US"))
+
+	confirming := map[uint16]uint64{}
+	queues := 0
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		if header[0] != 1 {
+			continue // content header, body or heartbeat
+		}
+
+		channel := binary.BigEndian.Uint16(header[1:])
+		class := binary.BigEndian.Uint16(payload)
+		method := binary.BigEndian.Uint16(payload[2:])
+		args := payload[4 : len(payload)-1]
+		if name, ok := topologyMethods[class<<8|method]; ok {
+			b.mu.Lock()
+			b.received = append(b.received, name)
+			b.mu.Unlock()
+		}
+		switch class<<8 | method {
+		case 10<<8 | 11: // connection.start-ok
+			w.method(0, 10, 30, uint16(2047), uint32(131072), uint16(0))
+		case 10<<8 | 40: // connection.open
+			w.method(0, 10, 41, "")
+		case 10<<8 | 50: // connection.close
+			b.closes.Add(1)
+			w.method(0, 10, 51)
+			return
+		case 10<<8 | 70: // connection.update-secret
+			secret := args[4 : 4+binary.BigEndian.Uint32(args)]
+			b.mu.Lock()
+			b.secrets = append(b.secrets, string(secret))
+			b.mu.Unlock()
+			w.method(0, 10, 71)
+		case 20<<8 | 10: // channel.open
+			w.method(channel, 20, 11, []byte{})
+		case 20<<8 | 40: // channel.close
+			delete(confirming, channel)
+			b.removeConsumer(w, channel, "")
+			w.method(channel, 20, 41)
+		case 40<<8 | 10, 40<<8 | 20, 40<<8 | 30, 50<<8 | 20, 50<<8 | 50, 60<<8 | 10, 85<<8 | 10:
+			// declare, delete, bind exchanges, bind and unbind queues, qos
+			// and confirm.select have empty replies
+			if class == 85 {
+				confirming[channel] = 0
+			}
+			w.method(channel, class, method+1)
+		case 40<<8 | 40: // exchange.unbind
+			w.method(channel, 40, 51)
+		case 50<<8 | 10: // queue.declare
+			name := shortString(args[2:])
+			if strings.HasPrefix(name, "missing") {
+				w.method(channel, 20, 40, uint16(404), "NOT_FOUND - no queue '"+name+"'", uint16(50), uint16(10))
+				continue
+			}
+			if name == "" {
+				queues++
+				name = fmt.Sprintf("amq.gen-%d", queues)
+			}
+			w.method(channel, 50, 11, name, uint32(0), uint32(0))
+		case 50<<8 | 30, 50<<8 | 40: // queue.purge, queue.delete
+			w.method(channel, 50, method+1, uint32(0))
+		case 60<<8 | 20: // basic.consume
+			queue := shortString(args[2:])
+			tag := shortString(args[3+len(queue):])
+			w.method(channel, 60, 21, tag)
+			b.mu.Lock()
+			b.consumers = append(b.consumers, fakeConsumer{w, channel, queue, tag})
+			b.mu.Unlock()
+		case 60<<8 | 30: // basic.cancel
+			b.removeConsumer(w, channel, shortString(args))
+			w.method(channel, 60, 31, shortString(args))
+		case 60<<8 | 80: // basic.ack
+			b.acks.Add(1)
+		case 60<<8 | 40: // basic.publish
+			b.publishes.Add(1)
+			exchange := shortString(args[2:])
+			key := shortString(args[3+len(exchange):])
+			mandatory := args[4+len(exchange)+len(key)]&1 != 0
+			b.mu.Lock()
+			b.keys = append(b.keys, key)
+			b.mu.Unlock()
+			if strings.HasPrefix(exchange, "missing") {
+				delete(confirming, channel)
+				b.removeConsumer(w, channel, "")
+				w.method(channel, 20, 40, uint16(404), "NOT_FOUND - no exchange '"+exchange+"'", uint16(60), uint16(40))
+				continue
+			}
+			if mandatory && strings.HasPrefix(exchange, "unroutable") {
+				header := binary.BigEndian.AppendUint16(nil, 60)
+				header = binary.BigEndian.AppendUint16(header, 0)
+				header = binary.BigEndian.AppendUint64(header, 0)
+				header = binary.BigEndian.AppendUint16(header, 0)
+				w.write(
+					methodFrame(channel, 60, 50, uint16(312), "NO_ROUTE", exchange, key),
+					frame(2, channel, header),
+				)
+			}
+			if tag, ok := confirming[channel]; ok && !b.holdConfirms.Load() {
+				confirming[channel] = tag + 1
+				w.method(channel, 60, 80, tag+1, uint8(0))
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package chamqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Contargo/chamqp"
+	"github.com/Contargo/chamqp/consume"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeBuild(t *testing.T) {
+	t.Run("sets the prefetch before consuming", func(t *testing.T) {
+		broker := chamqp.NewFakeBroker(t)
+		conn := chamqp.Dial(broker.URL(), chamqp.WithReconnectPolicy(chamqp.ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		ch := conn.Channel()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, conn.WaitReady(ctx))
+
+		consume.Consume("orders").
+			WithDeliveryChan(make(chan amqp.Delivery)).
+			Defaults().
+			WithPrefetch(10).
+			Build(ch)
+
+		assert.NoError(t, ch.WaitReady(ctx))
+		assert.Equal(t, []string{"basic.qos", "basic.consume"}, broker.Methods())
+	})
+}
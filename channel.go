@@ -1,9 +1,13 @@
 package chamqp
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -13,6 +17,10 @@ type NotifyPublishSpec struct {
 	confirm chan amqp.Confirmation
 }
 
+type NotifyReturnSpec struct {
+	returns chan amqp.Return
+}
+
 type ConsumeSpec struct {
 	Queue        string
 	Consumer     string
@@ -24,6 +32,14 @@ type ConsumeSpec struct {
 	NoWait    bool
 	Args      amqp.Table
 	ErrorChan chan<- error
+
+	// ContextDeliveryChan receives deliveries together with the trace
+	// context extracted from their headers. If set, it is used instead of
+	// DeliveryChan.
+	ContextDeliveryChan chan<- Delivery
+
+	// qos is the non-global Qos the consumer was started with.
+	qos QosSpec
 }
 
 type ExchangeDeclareSpec struct {
@@ -38,6 +54,19 @@ type ExchangeDeclareSpec struct {
 	ErrorChan chan<- error
 }
 
+// ExchangeBindSpec binds the Destination exchange to the Source exchange, so
+// that publishings to Source whose routing key matches Key are routed to
+// Destination.
+type ExchangeBindSpec struct {
+	Destination string
+	Key         string
+	Source      string
+	NoWait      bool
+	Args        amqp.Table
+
+	ErrorChan chan<- error
+}
+
 type QueueBindSpec struct {
 	Name     string
 	Key      string
@@ -60,6 +89,14 @@ type QueueDeclareSpec struct {
 	ErrorChan chan<- error
 }
 
+// QosSpec holds the prefetch limits of a channel. RabbitMQ applies them per
+// consumer, or shared by all consumers of the channel if Global is set.
+type QosSpec struct {
+	PrefetchCount int
+	PrefetchSize  int
+	Global        bool
+}
+
 type Properties struct {
 	ContentType     string    // MIME content type
 	ContentEncoding string    // MIME content encoding
@@ -77,67 +114,244 @@ type Properties struct {
 
 // Channel represents an AMQP channel. Used as a context for valid message
 // Exchange. Errors on methods with this Channel will be detected and the
-// channel will recreate itself.
+// channel will recreate itself. A Channel is safe for concurrent use.
 type Channel struct {
-	ch                   *amqp.Channel
+	ch                   atomic.Pointer[amqp.Channel]
+	specMu               sync.Mutex // guards the specs, held while replaying them
+	publishMu            sync.Mutex // keeps confirm sequence numbers in publish order
 	consumeSpecs         []ConsumeSpec
 	exchangeDeclareSpecs []ExchangeDeclareSpec
+	exchangeBindSpecs    []ExchangeBindSpec
 	queueBindSpecs       []QueueBindSpec
 	queueDeclareSpecs    []QueueDeclareSpec
+	qosSpecs             []QosSpec // at most one per value of Global
 	notifyPublishSpec    []NotifyPublishSpec
+	notifyReturnSpecs    []NotifyReturnSpec
 	confirm              bool
 	confirmNoWait        bool
+	logger               *slog.Logger
+	metrics              Metrics
+	propagator           Propagator
+	conn                 *Connection
+	pendingMu            sync.Mutex
+	pending              []pendingPublish
+	errorSubs            subscribers[error]
+	ready                readiness
+	deliveries           deliveryTracker
+	consumers            activeConsumers
+	unconfirmed          []*amqp.DeferredConfirmation // guarded by publishMu
 }
 
-func (ch *Channel) connected(conn *amqp.Connection) error {
-	channel, err := conn.Channel()
-	if ch.confirm {
-		channel.Confirm(ch.confirmNoWait)
+// NotifyError registers a listener for errors of this channel only, like a
+// channel exception closing it or a failed attempt to restore it. Sends never
+// block; errors that do not fit into the receiver are dropped.
+func (ch *Channel) NotifyError(receiver chan error) chan error {
+	ch.errorSubs.add(receiver, DropNewest)
+
+	return receiver
+}
+
+// SubscribeErrors is like NotifyError with the given overflow policy and a
+// way to unsubscribe.
+func (ch *Channel) SubscribeErrors(receiver chan error, overflow OverflowPolicy) *Subscription {
+	return ch.errorSubs.add(receiver, overflow)
+}
+
+func (ch *Channel) meter() Metrics {
+	if ch.metrics == nil {
+		return nopMetrics{}
+	}
+	return ch.metrics
+}
+
+func (ch *Channel) tracePropagator() Propagator {
+	if ch.propagator == nil {
+		return W3CPropagator{}
+	}
+	return ch.propagator
+}
+
+// Logger returns the logger configured on the parent Connection.
+func (ch *Channel) Logger() *slog.Logger {
+	if ch.logger == nil {
+		return discardLogger
+	}
+	return ch.logger
+}
+
+func (ch *Channel) connected(conn *amqp.Connection) (err error) {
+	if ch.ready.closed() {
+		// Restoring it gave up for good.
+		return nil
 	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+	defer func() {
+		if err != nil {
+			ch.ready.fail(err)
+		}
+	}()
+
+	channel, err := conn.Channel()
 	if err != nil {
-		ch.ch = nil
+		ch.ch.Store(nil)
 		return err
 	}
-	ch.ch = channel
+	if ch.confirm {
+		err := channel.Confirm(ch.confirmNoWait)
+		if err != nil {
+			ch.ch.Store(nil)
+			return err
+		}
+	}
+	ch.ch.Store(channel)
 
 	for _, spec := range ch.exchangeDeclareSpecs {
-		err := ch.applyExchangeDeclareSpec(spec)
+		err := ch.applyExchangeDeclareSpec(channel, spec)
+		if err != nil {
+			return err
+		}
+	}
+	for _, spec := range ch.exchangeBindSpecs {
+		err := ch.applyExchangeBindSpec(channel, spec)
 		if err != nil {
 			return err
 		}
 	}
 	for _, spec := range ch.queueDeclareSpecs {
-		err := ch.applyQueueDeclareSpec(spec)
+		err := ch.applyQueueDeclareSpec(channel, spec)
 		if err != nil {
 			return err
 		}
 	}
 	for _, spec := range ch.queueBindSpecs {
-		err := ch.applyQueueBindSpec(spec)
+		err := ch.applyQueueBindSpec(channel, spec)
+		if err != nil {
+			return err
+		}
+	}
+	for _, spec := range ch.qosSpecs {
+		if !spec.Global {
+			continue
+		}
+		err := ch.applyQosSpec(channel, spec)
 		if err != nil {
 			return err
 		}
 	}
+	// Every consumer starts with the prefetch it had before, the channel
+	// ends with the one for consumers to come.
+	var qos QosSpec
 	for _, spec := range ch.consumeSpecs {
-		err := ch.applyConsumeSpec(spec)
+		if spec.qos != qos {
+			err := ch.applyQosSpec(channel, spec.qos)
+			if err != nil {
+				return err
+			}
+			qos = spec.qos
+		}
+		err := ch.applyConsumeSpec(channel, spec)
+		if err != nil {
+			return err
+		}
+	}
+	if current := ch.consumerQos(); current != qos {
+		err := ch.applyQosSpec(channel, current)
 		if err != nil {
 			return err
 		}
 	}
 	for _, spec := range ch.notifyPublishSpec {
-		ch.applyNotifyPublishSpec(spec)
+		ch.applyNotifyPublishSpec(channel, spec)
+	}
+	for _, spec := range ch.notifyReturnSpecs {
+		ch.applyNotifyReturnSpec(channel, spec)
+	}
+	if ch.conn != nil {
+		go ch.watchClose(conn, channel, channel.NotifyClose(make(chan *amqp.Error, 1)))
 	}
+	ch.flushPending()
+	ch.ready.set(true)
 
 	return nil
 }
 
+// watchClose restores the channel after the server closed it, for example
+// with a 404 on publish to a missing exchange. Losing the connection is left
+// to the supervisor, which restores all channels.
+func (ch *Channel) watchClose(conn *amqp.Connection, channel *amqp.Channel, notifyClose <-chan *amqp.Error) {
+	amqpErr, ok := <-notifyClose
+	if !ok || amqpErr == nil || conn.IsClosed() {
+		return
+	}
+	if !ch.conn.channelFailed(ch, channel) {
+		return
+	}
+
+	ch.Logger().Warn("channel closed by server", "error", amqpErr)
+	ch.ready.fail(amqpErr)
+	ch.errorSubs.send(amqpErr)
+
+	for failures := 0; ; {
+		restored, err := ch.conn.restoreChannel(ch, conn)
+		if restored || err == nil {
+			return
+		}
+		ch.errorSubs.send(err)
+
+		failures++
+		delay, ok := ch.conn.opts.reconnectPolicy.NextBackoff(failures)
+		if !ok {
+			ch.Logger().Error("giving up restoring channel", "attempt", failures, "error", err)
+			final := fmt.Errorf("%w: %v", ErrRestoreGaveUp, err)
+			ch.ready.close(final)
+			ch.errorSubs.send(final)
+			return
+		}
+		ch.Logger().Warn("restoring channel failed", "attempt", failures, "backoff", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ch.conn.shutdownChan:
+			return
+		}
+	}
+}
+
 func (ch *Channel) disconnected() {
-	ch.ch = nil
+	ch.ch.Store(nil)
+	ch.ready.set(false)
+	ch.deliveries.reset()
 }
 
-func (ch *Channel) applyExchangeDeclareSpec(spec ExchangeDeclareSpec) error {
-	err := ch.ch.ExchangeDeclare(spec.Name, spec.Kind, spec.Durable, spec.AutoDelete, spec.Internal, spec.NoWait, spec.Args)
+// Close cancels all consumers, closes the channel and removes it from its
+// Connection, so it is not restored on reconnect. Deliveries already handed to
+// a DeliveryChan stay there; the DeliveryChan itself is not closed.
+func (ch *Channel) Close() error {
+	if ch.conn != nil {
+		ch.conn.removeChannel(ch)
+	}
+
+	ch.pendingMu.Lock()
+	for _, p := range ch.pending {
+		p.done(nil)
+	}
+	ch.pending = nil
+	ch.pendingMu.Unlock()
+	ch.ready.close(ErrClosed)
+
+	ch.cancelConsumers()
+	channel := ch.ch.Swap(nil)
+	if channel == nil {
+		return nil
+	}
+	return channel.Close()
+}
+
+func (ch *Channel) applyExchangeDeclareSpec(channel *amqp.Channel, spec ExchangeDeclareSpec) error {
+	err := channel.ExchangeDeclare(spec.Name, spec.Kind, spec.Durable, spec.AutoDelete, spec.Internal, spec.NoWait, spec.Args)
 	if err != nil {
+		ch.Logger().Error("exchange declare failed", "exchange", spec.Name, "error", err)
+		ch.ready.fail(err)
 		if spec.ErrorChan != nil {
 			spec.ErrorChan <- err
 		}
@@ -146,9 +360,24 @@ func (ch *Channel) applyExchangeDeclareSpec(spec ExchangeDeclareSpec) error {
 	return nil
 }
 
-func (ch *Channel) applyQueueDeclareSpec(spec QueueDeclareSpec) error {
-	queue, err := ch.ch.QueueDeclare(spec.Name, spec.Durable, spec.AutoDelete, spec.Exclusive, spec.NoWait, spec.Args)
+func (ch *Channel) applyExchangeBindSpec(channel *amqp.Channel, spec ExchangeBindSpec) error {
+	err := channel.ExchangeBind(spec.Destination, spec.Key, spec.Source, spec.NoWait, spec.Args)
 	if err != nil {
+		ch.Logger().Error("exchange bind failed", "destination", spec.Destination, "source", spec.Source, "key", spec.Key, "error", err)
+		ch.ready.fail(err)
+		if spec.ErrorChan != nil {
+			spec.ErrorChan <- err
+		}
+		return err
+	}
+	return nil
+}
+
+func (ch *Channel) applyQueueDeclareSpec(channel *amqp.Channel, spec QueueDeclareSpec) error {
+	queue, err := channel.QueueDeclare(spec.Name, spec.Durable, spec.AutoDelete, spec.Exclusive, spec.NoWait, spec.Args)
+	if err != nil {
+		ch.Logger().Error("queue declare failed", "queue", spec.Name, "error", err)
+		ch.ready.fail(err)
 		if spec.ErrorChan != nil {
 			spec.ErrorChan <- err
 		}
@@ -160,80 +389,195 @@ func (ch *Channel) applyQueueDeclareSpec(spec QueueDeclareSpec) error {
 	return nil
 }
 
-func (ch *Channel) applyQueueBindSpec(spec QueueBindSpec) error {
-	err := ch.ch.QueueBind(spec.Name, spec.Key, spec.Exchange, spec.NoWait, spec.Args)
+func (ch *Channel) applyQueueBindSpec(channel *amqp.Channel, spec QueueBindSpec) error {
+	err := channel.QueueBind(spec.Name, spec.Key, spec.Exchange, spec.NoWait, spec.Args)
 	if err != nil {
+		ch.Logger().Error("queue bind failed", "queue", spec.Name, "exchange", spec.Exchange, "key", spec.Key, "error", err)
+		ch.ready.fail(err)
 		if spec.ErrorChan != nil {
 			spec.ErrorChan <- err
 		}
-		spec.ErrorChan <- err
 		return err
 	}
 	return nil
 }
 
-func (ch *Channel) applyConsumeSpec(spec ConsumeSpec) error {
-	deliveries, err := ch.ch.Consume(spec.Queue, spec.Consumer, spec.AutoAck, spec.Exclusive, spec.NoLocal, spec.NoWait, spec.Args)
+func (ch *Channel) applyQosSpec(channel *amqp.Channel, spec QosSpec) error {
+	err := channel.Qos(spec.PrefetchCount, spec.PrefetchSize, spec.Global)
 	if err != nil {
+		ch.Logger().Error("qos failed", "prefetch_count", spec.PrefetchCount, "prefetch_size", spec.PrefetchSize, "global", spec.Global, "error", err)
+		ch.ready.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (ch *Channel) applyConsumeSpec(channel *amqp.Channel, spec ConsumeSpec) error {
+	deliveries, err := channel.Consume(spec.Queue, spec.Consumer, spec.AutoAck, spec.Exclusive, spec.NoLocal, spec.NoWait, spec.Args)
+	if err != nil {
+		ch.Logger().Error("consume failed", "queue", spec.Queue, "consumer", spec.Consumer, "error", err)
+		ch.ready.fail(err)
 		if spec.ErrorChan != nil {
 			spec.ErrorChan <- err
 		}
 		return err
 	}
-	if spec.DeliveryChan != nil {
-		go shovel(deliveries, spec.DeliveryChan)
+	labels := map[string]string{"queue": spec.Queue}
+	var tracker *deliveryTracker
+	if !spec.AutoAck {
+		tracker = &ch.deliveries
+	}
+	// The consumer is active until the broker or the channel ends its
+	// deliveries.
+	consumer := consumerKey{channel, spec.Consumer}
+	if spec.ContextDeliveryChan != nil {
+		ch.deliveries.startShovel()
+		ch.consumers.add(consumer, spec.Queue)
+		go func() {
+			defer ch.deliveries.stopShovel()
+			defer ch.consumers.remove(consumer)
+			shovelWithContext(deliveries, spec.ContextDeliveryChan, tracker, ch.tracePropagator(), ch.meter(), labels)
+		}()
+	} else if spec.DeliveryChan != nil {
+		ch.deliveries.startShovel()
+		ch.consumers.add(consumer, spec.Queue)
+		go func() {
+			defer ch.deliveries.stopShovel()
+			defer ch.consumers.remove(consumer)
+			shovel(deliveries, spec.DeliveryChan, tracker, ch.meter(), labels)
+		}()
 	}
 	return nil
 }
 
-func (ch *Channel) applyNotifyPublishSpec(spec NotifyPublishSpec) {
+func (ch *Channel) applyNotifyPublishSpec(channel *amqp.Channel, spec NotifyPublishSpec) {
 	subscribeChannel := make(chan amqp.Confirmation, 1)
-	go shovelConfirmation(subscribeChannel, spec.confirm)
-	ch.ch.NotifyPublish(subscribeChannel)
+	go shovelConfirmation(subscribeChannel, spec.confirm, ch.meter())
+	channel.NotifyPublish(subscribeChannel)
 }
 
+// consumerTags numbers the tags of consumers registered without one.
+var consumerTags atomic.Uint64
+
 func (ch *Channel) ConsumeWithSpec(spec ConsumeSpec) {
-	ch.Consume(spec.Queue, spec.Consumer, spec.AutoAck, spec.Exclusive, spec.NoLocal, spec.NoWait, spec.Args, spec.DeliveryChan, spec.ErrorChan)
+	if spec.Consumer == "" {
+		// A known tag allows cancelling the consumer on Close.
+		spec.Consumer = fmt.Sprintf("ctag-chamqp-%d", consumerTags.Add(1))
+	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
+	spec.qos = ch.consumerQos()
+	ch.consumeSpecs = append(ch.consumeSpecs, spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyConsumeSpec(channel, spec)
+	}
 }
 
 // Consume immediately starts delivering queued messages.
 func (ch *Channel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table, deliveryChan chan<- amqp.Delivery, errorChan chan<- error) {
-	spec := ConsumeSpec{
-		queue,
-		consumer,
-		deliveryChan,
-		autoAck,
-		exclusive,
-		noLocal,
-		noWait,
-		args,
-		errorChan,
-	}
-	ch.consumeSpecs = append(ch.consumeSpecs, spec)
-	if ch.ch != nil {
-		ch.applyConsumeSpec(spec)
-	}
+	ch.ConsumeWithSpec(ConsumeSpec{
+		Queue:        queue,
+		Consumer:     consumer,
+		DeliveryChan: deliveryChan,
+		AutoAck:      autoAck,
+		Exclusive:    exclusive,
+		NoLocal:      noLocal,
+		NoWait:       noWait,
+		Args:         args,
+		ErrorChan:    errorChan,
+	})
 }
 
 // Publish sends a Publishing from the client to an Exchange on the server.
 func (ch *Channel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
-	if ch.ch == nil {
+	return ch.PublishWithContext(context.Background(), exchange, key, mandatory, immediate, msg)
+}
+
+// PublishWithContext is like Publish but injects the trace context of ctx
+// into the message headers.
+func (ch *Channel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	_, err := ch.publishWithContext(ctx, pendingPublish{exchange: exchange, key: key, mandatory: mandatory, immediate: immediate, msg: msg})
+	return err
+}
+
+// publishWithContext returns the confirmation of the publish in confirm
+// mode. It is nil otherwise, or if the publish was buffered; p.sent receives
+// it then.
+func (ch *Channel) publishWithContext(ctx context.Context, p pendingPublish) (*amqp.DeferredConfirmation, error) {
+	labels := map[string]string{"exchange": p.exchange}
+	if err := ch.unavailable(); err != nil {
+		ch.meter().AddCounter(MetricPublishErrors, 1, labels)
+		return nil, err
+	}
+
+	p.msg.Headers = injectHeaders(ctx, ch.tracePropagator(), p.msg.Headers)
+	buffered, err := ch.admitPublish(ctx, p)
+	if err != nil {
+		ch.meter().AddCounter(MetricPublishErrors, 1, labels)
+		return nil, err
+	}
+	if buffered {
+		return nil, nil
+	}
+	confirmation, err := ch.publish(ctx, p)
+	if err != nil {
+		ch.meter().AddCounter(MetricPublishErrors, 1, labels)
+		return nil, err
+	}
+	ch.meter().AddCounter(MetricPublishes, 1, labels)
+	return confirmation, nil
+}
+
+// unavailable returns why publishing is not possible right now, if at all.
+func (ch *Channel) unavailable() error {
+	if ch.conn != nil && ch.conn.draining.Load() {
+		return ErrShuttingDown
+	}
+	if ch.ch.Load() == nil {
 		return fmt.Errorf("context has no channel")
 	}
+	return nil
+}
+
+// publish sends p on the current AMQP channel.
+func (ch *Channel) publish(ctx context.Context, p pendingPublish) (*amqp.DeferredConfirmation, error) {
+	ch.publishMu.Lock()
+	defer ch.publishMu.Unlock()
 
-	return ch.ch.Publish(exchange, key, mandatory, immediate, msg)
+	channel := ch.ch.Load()
+	if channel == nil {
+		return nil, fmt.Errorf("context has no channel")
+	}
+	ch.sendPending()
+	return ch.send(ctx, channel, p)
+}
+
+// send publishes p on channel. It must be called with publishMu held.
+func (ch *Channel) send(ctx context.Context, channel *amqp.Channel, p pendingPublish) (*amqp.DeferredConfirmation, error) {
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, p.key, p.mandatory, p.immediate, p.msg)
+	if confirmation != nil {
+		ch.trackConfirmation(confirmation)
+	}
+	return confirmation, err
 }
 
 func (ch *Channel) PublishJSONWithProperties(exchange, key string, mandatory, immediate bool, objectToBeSent interface{}, properties Properties) error {
-	if ch.ch == nil {
-		return fmt.Errorf("context has no channel")
+	return ch.PublishJSONWithPropertiesWithContext(context.Background(), exchange, key, mandatory, immediate, objectToBeSent, properties)
+}
+
+// PublishJSONWithPropertiesWithContext is like PublishJSONWithProperties but
+// injects the trace context of ctx into the message headers.
+func (ch *Channel) PublishJSONWithPropertiesWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, objectToBeSent interface{}, properties Properties) error {
+	if err := ch.unavailable(); err != nil {
+		return err
 	}
 
 	payload, err := json.Marshal(objectToBeSent)
 	if err != nil {
 		return err
 	}
-	return ch.Publish(exchange, key, mandatory, immediate, amqp.Publishing{
+	return ch.PublishWithContext(ctx, exchange, key, mandatory, immediate, amqp.Publishing{
 		ContentType: "application/json",
 		Body:        payload,
 
@@ -252,26 +596,40 @@ func (ch *Channel) PublishJSONWithProperties(exchange, key string, mandatory, im
 }
 
 func (ch *Channel) PublishJSON(exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
-	if ch.ch == nil {
-		return fmt.Errorf("context has no channel")
+	return ch.PublishJSONWithContext(context.Background(), exchange, key, mandatory, immediate, objectToBeSent)
+}
+
+// PublishJSONWithContext is like PublishJSON but injects the trace context
+// of ctx into the message headers.
+func (ch *Channel) PublishJSONWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
+	if err := ch.unavailable(); err != nil {
+		return err
 	}
 
 	payload, err := json.Marshal(objectToBeSent)
 	if err != nil {
 		return err
 	}
-	return ch.Publish(exchange, key, mandatory, immediate, amqp.Publishing{
+	return ch.PublishWithContext(ctx, exchange, key, mandatory, immediate, amqp.Publishing{
 		ContentType: "application/json",
 		Body:        payload,
 	})
 }
 
 func (ch *Channel) PublishJsonAndWaitForResponse(replyQueueName, correlationId string, response, request interface{}, exchange, key string, mandatory, immediate bool, responseTimeout time.Duration) error {
-	if ch.ch == nil {
+	return ch.PublishJsonAndWaitForResponseWithContext(context.Background(), replyQueueName, correlationId, response, request, exchange, key, mandatory, immediate, responseTimeout)
+}
+
+// PublishJsonAndWaitForResponseWithContext is like
+// PublishJsonAndWaitForResponse but injects the trace context of ctx into the
+// request headers and stops waiting once ctx is done.
+func (ch *Channel) PublishJsonAndWaitForResponseWithContext(ctx context.Context, replyQueueName, correlationId string, response, request interface{}, exchange, key string, mandatory, immediate bool, responseTimeout time.Duration) error {
+	channel := ch.ch.Load()
+	if channel == nil {
 		return errors.New("channel not present")
 	}
-	defer ch.ch.Cancel(replyQueueName+".consumer", false)
-	replyQueue, err := ch.ch.Consume(replyQueueName, replyQueueName+".consumer", true, false, false, false, nil)
+	defer channel.Cancel(replyQueueName+".consumer", false)
+	replyQueue, err := channel.Consume(replyQueueName, replyQueueName+".consumer", true, false, false, false, nil)
 	if err != nil {
 		return err
 	}
@@ -288,26 +646,31 @@ func (ch *Channel) PublishJsonAndWaitForResponse(replyQueueName, correlationId s
 		CorrelationId: correlationId,
 		Body:          payload,
 	}
-	err = ch.Publish(exchange, key, mandatory, immediate, msg)
+	err = ch.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
 	if err != nil {
 		return err
 	}
 
 	timer := time.NewTimer(responseTimeout)
+	defer timer.Stop()
 	for {
 		select {
 		case reply := <-replyQueue:
 			if correlationId != "" && reply.CorrelationId != correlationId {
-				fmt.Println("skipping, not for me")
+				ch.Logger().Debug("skipping reply for another request", "correlation_id", reply.CorrelationId, "expected_correlation_id", correlationId)
 				continue
 			}
 			err := json.Unmarshal(reply.Body, response)
 			if err != nil {
+				ch.Logger().Warn("skipping undecodable reply", "correlation_id", reply.CorrelationId, "error", err)
 				continue
 			}
 			return err
 		case <-timer.C:
+			ch.Logger().Warn("no reply received", "reply_queue", replyQueueName, "correlation_id", correlationId, "timeout", responseTimeout)
 			return errors.New("timed out")
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
@@ -330,9 +693,73 @@ func (ch *Channel) ExchangeDeclare(name, kind string, durable, autoDelete, inter
 		args,
 		errorChan,
 	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
 	ch.exchangeDeclareSpecs = append(ch.exchangeDeclareSpecs, spec)
-	if ch.ch != nil {
-		ch.applyExchangeDeclareSpec(spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyExchangeDeclareSpec(channel, spec)
+	}
+}
+
+// Qos limits how many messages or bytes the broker delivers before waiting for
+// acknowledgements, see amqp.Channel.Qos. Without global the limits apply to
+// consumers started afterwards; on reconnect every consumer is restored with
+// the limits it was started with. Global limits are restored before all
+// consumers, a later call with the same global replaces them. Without an open
+// channel they are only stored and nil is returned.
+func (ch *Channel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	spec := QosSpec{
+		prefetchCount,
+		prefetchSize,
+		global,
+	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
+	ch.qosSpecs = append(without(ch.qosSpecs, func(qos QosSpec) bool {
+		return qos.Global == global
+	}), spec)
+	if channel := ch.ch.Load(); channel != nil {
+		return ch.applyQosSpec(channel, spec)
+	}
+	return nil
+}
+
+// consumerQos returns the non-global Qos consumers are started with. It
+// must be called with specMu held.
+func (ch *Channel) consumerQos() QosSpec {
+	for _, spec := range ch.qosSpecs {
+		if !spec.Global {
+			return spec
+		}
+	}
+	return QosSpec{}
+}
+
+func (ch *Channel) ExchangeBindWithSpec(spec ExchangeBindSpec) {
+	ch.ExchangeBind(spec.Destination, spec.Key, spec.Source, spec.NoWait, spec.Args, spec.ErrorChan)
+}
+
+// ExchangeBind binds the destination exchange to the source exchange so that
+// publishings to source are routed to destination when the publishing routing
+// key matches the binding routing key. The binding is restored on reconnect
+// after all exchanges are declared and before queues are bound.
+func (ch *Channel) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table, errorChan chan<- error) {
+	spec := ExchangeBindSpec{
+		destination,
+		key,
+		source,
+		noWait,
+		args,
+		errorChan,
+	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
+	ch.exchangeBindSpecs = append(ch.exchangeBindSpecs, spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyExchangeBindSpec(channel, spec)
 	}
 }
 
@@ -352,9 +779,12 @@ func (ch *Channel) QueueBind(name, key, exchange string, noWait bool, args amqp.
 		args,
 		errorChan,
 	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
 	ch.queueBindSpecs = append(ch.queueBindSpecs, spec)
-	if ch.ch != nil {
-		ch.applyQueueBindSpec(spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyQueueBindSpec(channel, spec)
 	}
 }
 
@@ -376,31 +806,84 @@ func (ch *Channel) QueueDeclare(name string, durable, autoDelete, exclusive, noW
 		queueChan,
 		errorChan,
 	}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
 	ch.queueDeclareSpecs = append(ch.queueDeclareSpecs, spec)
-	if ch.ch != nil {
-		ch.applyQueueDeclareSpec(spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyQueueDeclareSpec(channel, spec)
 	}
 }
 
 func (ch *Channel) NotifyPublish() chan amqp.Confirmation {
 	notifyPublishChan := make(chan amqp.Confirmation, 1)
 	spec := NotifyPublishSpec{notifyPublishChan}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
 	ch.notifyPublishSpec = append(ch.notifyPublishSpec, spec)
-	if ch.ch != nil {
-		ch.applyNotifyPublishSpec(spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyNotifyPublishSpec(channel, spec)
 	}
 	return notifyPublishChan
 }
 
 // Shovel takes messages from `src` and puts them into `dest`.
-func shovel(src <-chan amqp.Delivery, dest chan<- amqp.Delivery) {
+func shovel(src <-chan amqp.Delivery, dest chan<- amqp.Delivery, tracker *deliveryTracker, metrics Metrics, labels map[string]string) {
+	for msg := range src {
+		tracker.track(&msg)
+		metrics.AddCounter(MetricDeliveries, 1, labels)
+		metrics.ObserveHistogram(MetricShovelBufferOccupancy, float64(len(dest)), labels)
+		dest <- msg
+	}
+}
+
+func shovelWithContext(src <-chan amqp.Delivery, dest chan<- Delivery, tracker *deliveryTracker, propagator Propagator, metrics Metrics, labels map[string]string) {
+	for msg := range src {
+		tracker.track(&msg)
+		metrics.AddCounter(MetricDeliveries, 1, labels)
+		metrics.ObserveHistogram(MetricShovelBufferOccupancy, float64(len(dest)), labels)
+		dest <- Delivery{msg, propagator.Extract(context.Background(), msg.Headers)}
+	}
+}
+
+func (ch *Channel) applyNotifyReturnSpec(channel *amqp.Channel, spec NotifyReturnSpec) {
+	subscribeChannel := make(chan amqp.Return, 1)
+	go shovelReturn(subscribeChannel, spec.returns, ch.meter())
+	channel.NotifyReturn(subscribeChannel)
+}
+
+// NotifyReturn returns a channel receiving publishings sent with mandatory or
+// immediate that the broker could not route. It stays the same across
+// reconnects and is never closed. It must be drained, since the underlying
+// connection blocks until a return is received.
+func (ch *Channel) NotifyReturn() chan amqp.Return {
+	notifyReturnChan := make(chan amqp.Return, 1)
+	spec := NotifyReturnSpec{notifyReturnChan}
+	ch.specMu.Lock()
+	defer ch.specMu.Unlock()
+
+	ch.notifyReturnSpecs = append(ch.notifyReturnSpecs, spec)
+	if channel := ch.ch.Load(); channel != nil {
+		ch.applyNotifyReturnSpec(channel, spec)
+	}
+	return notifyReturnChan
+}
+
+func shovelReturn(src, dest chan amqp.Return, metrics Metrics) {
 	for msg := range src {
+		metrics.AddCounter(MetricReturns, 1, map[string]string{"exchange": msg.Exchange})
 		dest <- msg
 	}
 }
 
-func shovelConfirmation(src, dest chan amqp.Confirmation) {
+func shovelConfirmation(src, dest chan amqp.Confirmation, metrics Metrics) {
 	for msg := range src {
+		if msg.Ack {
+			metrics.AddCounter(MetricConfirmAcks, 1, nil)
+		} else {
+			metrics.AddCounter(MetricConfirmNacks, 1, nil)
+		}
 		dest <- msg
 	}
 }
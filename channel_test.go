@@ -0,0 +1,322 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelClose(t *testing.T) {
+	t.Run("deregisters from the connection", func(t *testing.T) {
+		conn := newConnection(nil)
+		first := conn.Channel()
+		second := conn.Channel()
+
+		assert.NoError(t, first.Close())
+		assert.NoError(t, first.Close())
+
+		assert.Equal(t, []*Channel{second}, conn.channels)
+	})
+
+	t.Run("publishing on a closed channel fails", func(t *testing.T) {
+		ch := newConnection(nil).Channel()
+		assert.NoError(t, ch.Close())
+		assert.Error(t, ch.PublishJSON("exchange", "key", false, false, "payload"))
+	})
+}
+
+func TestConsumerTags(t *testing.T) {
+	t.Run("consumers without a tag get a unique one", func(t *testing.T) {
+		ch := &Channel{}
+		ch.Consume("queue", "", true, false, false, false, nil, nil, nil)
+		ch.Consume("queue", "", true, false, false, false, nil, nil, nil)
+		ch.Consume("queue", "named", true, false, false, false, nil, nil, nil)
+
+		assert.NotEmpty(t, ch.consumeSpecs[0].Consumer)
+		assert.NotEqual(t, ch.consumeSpecs[0].Consumer, ch.consumeSpecs[1].Consumer)
+		assert.Equal(t, "named", ch.consumeSpecs[2].Consumer)
+	})
+}
+
+func TestRestoreChannel(t *testing.T) {
+	t.Run("nothing to restore once the connection was replaced", func(t *testing.T) {
+		conn := newConnection(nil)
+		ch := conn.Channel()
+
+		restored, err := conn.restoreChannel(ch, &amqp.Connection{})
+		assert.True(t, restored)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing to restore once the channel was closed", func(t *testing.T) {
+		conn := newConnection(nil)
+		conn.conn = &amqp.Connection{}
+		ch := &Channel{conn: conn}
+
+		restored, err := conn.restoreChannel(ch, conn.conn)
+		assert.True(t, restored)
+		assert.NoError(t, err)
+	})
+
+	t.Run("only the current amqp channel is marked as failed", func(t *testing.T) {
+		conn := newConnection(nil)
+		current := &amqp.Channel{}
+		ch := &Channel{conn: conn}
+		ch.ch.Store(current)
+
+		assert.False(t, conn.channelFailed(ch, &amqp.Channel{}))
+		assert.Same(t, current, ch.ch.Load())
+		assert.True(t, conn.channelFailed(ch, current))
+		assert.Nil(t, ch.ch.Load())
+	})
+
+	t.Run("a channel closed by the server is restored alone", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		sibling := conn.Channel()
+		failing := conn.Channel()
+		failing.QueueDeclare("orders", true, false, false, false, nil, nil, nil)
+		errs := failing.NotifyError(make(chan error, 4))
+		waitReady(t, conn)
+		siblingChannel, failingChannel := sibling.ch.Load(), failing.ch.Load()
+
+		assert.NoError(t, failing.Publish("missing", "key", false, false, amqp.Publishing{}))
+		select {
+		case err := <-errs:
+			var amqpErr *amqp.Error
+			assert.ErrorAs(t, err, &amqpErr)
+			assert.Equal(t, 404, amqpErr.Code)
+		case <-time.After(time.Second):
+			t.Fatal("no channel error received")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.Eventually(t, func() bool {
+			restored := failing.ch.Load()
+			return restored != nil && restored != failingChannel
+		}, time.Second, time.Millisecond)
+		assert.NoError(t, failing.WaitReady(ctx))
+		assert.Same(t, siblingChannel, sibling.ch.Load())
+		assert.Equal(t, StateTopologyApplied, conn.State())
+		assert.Equal(t, []string{"queue.declare", "queue.declare"}, broker.methods())
+	})
+
+	t.Run("giving up closes the channel for good", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ExponentialBackoff{InitialInterval: time.Millisecond, MaxAttempts: 1}))
+		defer conn.Close()
+		ch := conn.Channel()
+		errs := ch.NotifyError(make(chan error, 4))
+		waitReady(t, conn)
+
+		// Every restore replays the failing declaration.
+		ch.QueueDeclare("missing", true, false, false, false, nil, nil, nil)
+
+		assert.Eventually(t, func() bool { return errors.Is(ch.Status().Err, ErrRestoreGaveUp) }, time.Second, time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.ErrorIs(t, ch.WaitReady(ctx), ErrRestoreGaveUp)
+		assert.Eventually(t, func() bool {
+			for {
+				select {
+				case err := <-errs:
+					if errors.Is(err, ErrRestoreGaveUp) {
+						return true
+					}
+				default:
+					return false
+				}
+			}
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, StateTopologyApplied, conn.State())
+
+		// A reconnect does not replay the channel either.
+		broker.dropConnections()
+		assert.Eventually(t, func() bool {
+			return conn.Status().Reconnects == 1 && conn.State() == StateTopologyApplied
+		}, time.Second, time.Millisecond)
+		assert.ErrorIs(t, ch.WaitReady(ctx), ErrRestoreGaveUp)
+	})
+}
+
+func TestChannelConcurrency(t *testing.T) {
+	t.Run("publish and register specs during reconnects", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		ch := &Channel{}
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-time.After(100 * time.Microsecond):
+						ch.Publish("exchange", "key", false, false, amqp.Publishing{Body: []byte("payload")})
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				ch.QueueDeclare(fmt.Sprintf("queue-%d", i), false, true, false, false, nil, nil, nil)
+				ch.Consume(fmt.Sprintf("queue-%d", i), "", true, false, false, false, nil, nil, nil)
+			}
+		}()
+
+		for i := 0; i < 5; i++ {
+			conn, err := amqp.Dial(broker.url())
+			assert.NoError(t, err)
+			assert.NoError(t, ch.connected(conn))
+			time.Sleep(5 * time.Millisecond)
+			ch.disconnected()
+			conn.Close()
+		}
+		close(stop)
+		wg.Wait()
+
+		assert.Len(t, ch.queueDeclareSpecs, 20)
+		assert.Len(t, ch.consumeSpecs, 20)
+		assert.Positive(t, broker.publishes.Load())
+	})
+
+	t.Run("publish while the broker drops connections", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		ch := conn.ChannelWithConfirm(false)
+		confirms := ch.NotifyPublish()
+		go func() {
+			for range confirms {
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					ch.Publish("exchange", "key", false, false, amqp.Publishing{Body: []byte("payload")})
+				}
+			}()
+		}
+		for i := 0; i < 3; i++ {
+			time.Sleep(5 * time.Millisecond)
+			broker.dropConnections()
+		}
+		wg.Wait()
+
+		assert.Eventually(t, func() bool {
+			return ch.Publish("exchange", "key", false, false, amqp.Publishing{}) == nil
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestExchangeBind(t *testing.T) {
+	t.Run("replays after exchange declares and before queue binds", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.QueueBind("orders", "orders.#", "domain", false, nil, nil)
+		ch.ExchangeBind("domain", "orders.#", "central", false, nil, nil)
+		ch.ExchangeDeclare("central", "topic", true, false, false, false, nil, nil)
+		ch.ExchangeDeclare("domain", "topic", true, false, false, false, nil, nil)
+		waitReady(t, conn)
+
+		before := len(broker.methods())
+		broker.dropConnections()
+		expected := []string{"exchange.declare", "exchange.declare", "exchange.bind", "queue.bind"}
+		assert.Eventually(t, func() bool {
+			return len(broker.methods()) == before+len(expected) && conn.State() == StateTopologyApplied
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, expected, broker.methods()[before:])
+	})
+
+	t.Run("unbind removes the binding from the replay", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.ExchangeBind("domain", "orders.#", "central", false, nil, nil)
+		ch.ExchangeBind("domain", "invoices.#", "central", false, nil, nil)
+		waitReady(t, conn)
+
+		assert.NoError(t, ch.ExchangeUnbind("domain", "orders.#", "central", false, nil))
+		assert.Equal(t, []ExchangeBindSpec{{Destination: "domain", Key: "invoices.#", Source: "central"}}, ch.exchangeBindSpecs)
+		assert.Equal(t, []string{"exchange.bind", "exchange.bind", "exchange.unbind"}, broker.methods())
+	})
+}
+
+func TestQos(t *testing.T) {
+	t.Run("replays each consumer with its prefetch", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.Consume("orders", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		assert.NoError(t, ch.Qos(10, 0, false))
+		ch.Consume("invoices", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		ch.Consume("shipments", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		assert.NoError(t, ch.Qos(5, 0, false))
+		waitReady(t, conn)
+
+		before := len(broker.methods())
+		broker.dropConnections()
+		expected := []string{"basic.consume", "basic.qos", "basic.consume", "basic.consume", "basic.qos"}
+		assert.Eventually(t, func() bool {
+			return len(broker.methods()) == before+len(expected) && conn.State() == StateTopologyApplied
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, expected, broker.methods()[before:])
+	})
+
+	t.Run("keeps the last setting per scope", func(t *testing.T) {
+		ch := &Channel{}
+		assert.NoError(t, ch.Qos(10, 0, false))
+		assert.NoError(t, ch.Qos(100, 0, true))
+		assert.NoError(t, ch.Qos(5, 0, false))
+
+		assert.Equal(t, []QosSpec{{PrefetchCount: 100, Global: true}, {PrefetchCount: 5}}, ch.qosSpecs)
+	})
+}
+
+func TestNotifyReturn(t *testing.T) {
+	t.Run("delivers returns across reconnects", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		returns := ch.NotifyReturn()
+		waitReady(t, conn)
+
+		assert.NoError(t, ch.Publish("unroutable", "orders.created", true, false, amqp.Publishing{}))
+		select {
+		case returned := <-returns:
+			assert.Equal(t, uint16(312), returned.ReplyCode)
+			assert.Equal(t, "unroutable", returned.Exchange)
+			assert.Equal(t, "orders.created", returned.RoutingKey)
+		case <-time.After(time.Second):
+			t.Fatal("no return received")
+		}
+
+		broker.dropConnections()
+		assert.Eventually(t, func() bool {
+			ch.Publish("unroutable", "orders.created", true, false, amqp.Publishing{})
+			select {
+			case <-returns:
+				return true
+			case <-time.After(10 * time.Millisecond):
+				return false
+			}
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,238 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Client owns separate connections for publishing and consuming, as
+// recommended by RabbitMQ: flow control blocking the publish connection does
+// not hold back acknowledgements on the consume connection.
+type Client struct {
+	publisher *Connection
+	consumer  *Connection
+	publish   *Channel
+	consume   *Channel
+}
+
+// DialClient is like Dial but opens a publish and a consume connection. Their
+// connection_name client property is suffixed with "-publisher" and
+// "-consumer" respectively.
+func DialClient(url string, opts ...Option) *Client {
+	return NewClient(
+		Dial(url, append(opts[:len(opts):len(opts)], withConnectionRole("publisher"))...),
+		Dial(url, append(opts[:len(opts):len(opts)], withConnectionRole("consumer"))...),
+	)
+}
+
+// NewClient combines two connections, for example from DialCluster, into a
+// Client. It takes ownership of both.
+func NewClient(publisher, consumer *Connection) *Client {
+	return &Client{
+		publisher: publisher,
+		consumer:  consumer,
+		publish:   publisher.Channel(),
+		consume:   consumer.Channel(),
+	}
+}
+
+func withConnectionRole(role string) Option {
+	return func(o *options) {
+		properties := amqp.Table{}
+		for key, value := range o.config.Properties {
+			properties[key] = value
+		}
+		name, _ := properties["connection_name"].(string)
+		if name == "" {
+			name = "chamqp"
+		}
+		properties["connection_name"] = name + "-" + role
+		o.config.Properties = properties
+	}
+}
+
+// Publisher returns the connection used for publishing, for example to open a
+// ChannelPool on it.
+func (c *Client) Publisher() *Connection {
+	return c.publisher
+}
+
+// Consumer returns the connection used for consuming.
+func (c *Client) Consumer() *Connection {
+	return c.consumer
+}
+
+// State returns the state of the connection furthest from being usable, so
+// the Client is only StateTopologyApplied once both connections are.
+func (c *Client) State() State {
+	return worseState(c.publisher.State(), c.consumer.State())
+}
+
+// stateRank orders states from usable to unusable.
+var stateRank = map[State]int{
+	StateTopologyApplied: 0,
+	StateConnected:       1,
+	StateConnecting:      2,
+	StateReconnecting:    3,
+	StateDisconnected:    4,
+	StateClosed:          5,
+}
+
+func worseState(a, b State) State {
+	if stateRank[b] > stateRank[a] {
+		return b
+	}
+	return a
+}
+
+// Health returns nil if both connections are up with their topology applied,
+// and an error naming the failing side otherwise.
+func (c *Client) Health() error {
+	var errs []error
+	for _, side := range []struct {
+		name string
+		conn *Connection
+	}{{"publisher", c.publisher}, {"consumer", c.consumer}} {
+		state := side.conn.State()
+		if state == StateTopologyApplied {
+			continue
+		}
+		err := fmt.Errorf("chamqp: %s connection is %s", side.name, state)
+		if side.conn.Err() != nil {
+			err = fmt.Errorf("%w: %w", err, side.conn.Err())
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Err returns why the ReconnectPolicy of either connection gave up.
+func (c *Client) Err() error {
+	return errors.Join(c.publisher.Err(), c.consumer.Err())
+}
+
+// NotifyError registers receiver for errors of both connections.
+func (c *Client) NotifyError(receiver chan error) chan error {
+	c.publisher.NotifyError(receiver)
+	c.consumer.NotifyError(receiver)
+
+	return receiver
+}
+
+// Close closes both connections.
+func (c *Client) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but gives up waiting once ctx is done.
+func (c *Client) CloseContext(ctx context.Context) error {
+	return errors.Join(c.publisher.CloseContext(ctx), c.consumer.CloseContext(ctx))
+}
+
+// Shutdown drains the consume connection first, so handlers can still
+// publish while finishing their deliveries, then the publish connection. See
+// Connection.Shutdown.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return errors.Join(c.consumer.Shutdown(ctx), c.publisher.Shutdown(ctx))
+}
+
+// Publish sends a Publishing on the publish connection.
+func (c *Client) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return c.publish.Publish(exchange, key, mandatory, immediate, msg)
+}
+
+// PublishWithContext is like Publish but injects the trace context of ctx
+// into the message headers.
+func (c *Client) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return c.publish.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+}
+
+func (c *Client) PublishJSON(exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
+	return c.publish.PublishJSON(exchange, key, mandatory, immediate, objectToBeSent)
+}
+
+func (c *Client) PublishJSONWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
+	return c.publish.PublishJSONWithContext(ctx, exchange, key, mandatory, immediate, objectToBeSent)
+}
+
+func (c *Client) PublishJSONWithProperties(exchange, key string, mandatory, immediate bool, objectToBeSent interface{}, properties Properties) error {
+	return c.publish.PublishJSONWithProperties(exchange, key, mandatory, immediate, objectToBeSent, properties)
+}
+
+// NotifyReturn returns a channel receiving unroutable publishings of the
+// publish connection, see Channel.NotifyReturn.
+func (c *Client) NotifyReturn() chan amqp.Return {
+	return c.publish.NotifyReturn()
+}
+
+// Consume registers a consumer on the consume connection.
+func (c *Client) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table, deliveryChan chan<- amqp.Delivery, errorChan chan<- error) {
+	c.consume.Consume(queue, consumer, autoAck, exclusive, noLocal, noWait, args, deliveryChan, errorChan)
+}
+
+func (c *Client) ConsumeWithSpec(spec ConsumeSpec) {
+	c.consume.ConsumeWithSpec(spec)
+}
+
+// Qos sets the prefetch limits of the consume connection's channel.
+func (c *Client) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return c.consume.Qos(prefetchCount, prefetchSize, global)
+}
+
+// ExchangeDeclare declares an exchange on the consume connection. Like all
+// declarations of a Client it is replayed whenever that connection is
+// restored; exclusive queues belong to it anyway.
+func (c *Client) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table, errorChan chan<- error) {
+	c.consume.ExchangeDeclare(name, kind, durable, autoDelete, internal, noWait, args, errorChan)
+}
+
+func (c *Client) ExchangeDeclareWithSpec(spec ExchangeDeclareSpec) {
+	c.consume.ExchangeDeclareWithSpec(spec)
+}
+
+func (c *Client) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table, errorChan chan<- error) {
+	c.consume.ExchangeBind(destination, key, source, noWait, args, errorChan)
+}
+
+func (c *Client) ExchangeBindWithSpec(spec ExchangeBindSpec) {
+	c.consume.ExchangeBindWithSpec(spec)
+}
+
+func (c *Client) ExchangeUnbind(destination, key, source string, noWait bool, args amqp.Table) error {
+	return c.consume.ExchangeUnbind(destination, key, source, noWait, args)
+}
+
+func (c *Client) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table, queueChan chan<- amqp.Queue, errorChan chan<- error) {
+	c.consume.QueueDeclare(name, durable, autoDelete, exclusive, noWait, args, queueChan, errorChan)
+}
+
+func (c *Client) QueueDeclareWithSpec(spec QueueDeclareSpec) {
+	c.consume.QueueDeclareWithSpec(spec)
+}
+
+func (c *Client) QueueBind(name, key, exchange string, noWait bool, args amqp.Table, errorChan chan<- error) {
+	c.consume.QueueBind(name, key, exchange, noWait, args, errorChan)
+}
+
+func (c *Client) QueueBindWithSpec(spec QueueBindSpec) {
+	c.consume.QueueBindWithSpec(spec)
+}
+
+func (c *Client) QueueUnbind(name, key, exchange string, args amqp.Table) error {
+	return c.consume.QueueUnbind(name, key, exchange, args)
+}
+
+func (c *Client) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	return c.consume.QueueDelete(name, ifUnused, ifEmpty, noWait)
+}
+
+func (c *Client) QueuePurge(name string, noWait bool) (int, error) {
+	return c.consume.QueuePurge(name, noWait)
+}
+
+func (c *Client) ExchangeDelete(name string, ifUnused, noWait bool) error {
+	return c.consume.ExchangeDelete(name, ifUnused, noWait)
+}
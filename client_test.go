@@ -0,0 +1,78 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	t.Run("publishes and consumes on separate connections", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		client := DialClient(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer client.Close()
+
+		client.QueueDeclare("orders", true, false, false, false, nil, nil, nil)
+		client.Consume("orders", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		assert.Eventually(t, func() bool { return client.State() == StateTopologyApplied }, time.Second, time.Millisecond)
+		assert.NoError(t, client.Health())
+		assert.NoError(t, client.PublishJSON("exchange", "key", false, false, "payload"))
+
+		assert.Len(t, client.consume.consumeSpecs, 1)
+		assert.Len(t, client.consume.queueDeclareSpecs, 1)
+		assert.Empty(t, client.publish.consumeSpecs)
+		assert.Equal(t, "chamqp-publisher", client.Publisher().opts.config.Properties["connection_name"])
+		assert.Equal(t, "chamqp-consumer", client.Consumer().opts.config.Properties["connection_name"])
+
+		assert.NoError(t, client.Close())
+		assert.Equal(t, StateClosed, client.State())
+	})
+
+	t.Run("shutdown drains both connections", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		client := DialClient(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		assert.Eventually(t, func() bool { return client.State() == StateTopologyApplied }, time.Second, time.Millisecond)
+
+		assert.NoError(t, client.Shutdown(context.Background()))
+		assert.Equal(t, StateClosed, client.Publisher().State())
+		assert.Equal(t, StateClosed, client.Consumer().State())
+		assert.ErrorIs(t, client.PublishJSON("exchange", "key", false, false, "late"), ErrShuttingDown)
+	})
+
+	t.Run("health names the failing side", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		gaveUp := make(chan error, 1)
+		client := NewClient(
+			Dial(broker.url()),
+			DialConfig(unreachableURL, amqp.Config{
+				Dial: func(network, addr string) (net.Conn, error) {
+					return nil, errors.New("refused")
+				},
+			},
+				WithReconnectPolicy(ExponentialBackoff{InitialInterval: time.Millisecond, MaxAttempts: 1}),
+				WithGiveUp(func(err error) { gaveUp <- err }),
+			),
+		)
+		defer client.Close()
+
+		<-gaveUp
+		assert.Eventually(t, func() bool { return client.Publisher().State() == StateTopologyApplied }, time.Second, time.Millisecond)
+		assert.Equal(t, StateClosed, client.State())
+		assert.ErrorContains(t, client.Health(), "chamqp: consumer connection is closed")
+		assert.NotContains(t, client.Health().Error(), "publisher")
+		assert.ErrorIs(t, client.Err(), ErrReconnectGaveUp)
+	})
+
+	t.Run("connection name from the config is kept as prefix", func(t *testing.T) {
+		opts := newOptions([]Option{
+			WithConfig(amqp.Config{Properties: amqp.Table{"connection_name": "billing"}}),
+			withConnectionRole("publisher"),
+		})
+		assert.Equal(t, "billing-publisher", opts.config.Properties["connection_name"])
+	})
+}
@@ -0,0 +1,115 @@
+package chamqp
+
+import (
+	"errors"
+	"math/rand"
+	"net/url"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errNoEndpoints = errors.New("chamqp: no cluster endpoints configured")
+
+// EndpointStrategy picks the cluster node for the next connection attempt.
+type EndpointStrategy interface {
+	// Next returns the index of the endpoint to dial out of n endpoints.
+	// current is the index dialed before, or -1 for the first attempt. failed
+	// reports whether dialing current failed, as opposed to an established
+	// connection being lost.
+	Next(n, current int, failed bool) int
+}
+
+// RoundRobin dials the endpoints one after another on every reconnect.
+type RoundRobin struct{}
+
+// Next implements EndpointStrategy.
+func (RoundRobin) Next(n, current int, failed bool) int {
+	return (current + 1) % n
+}
+
+// Random dials a randomly chosen endpoint on every reconnect.
+type Random struct{}
+
+// Next implements EndpointStrategy.
+func (Random) Next(n, current int, failed bool) int {
+	return rand.Intn(n)
+}
+
+// PriorityFallback always starts with the first endpoint and only moves down
+// the list while dialing fails. Combine it with WithReturnToPreferred to move
+// back to the first endpoint once it is reachable again.
+type PriorityFallback struct{}
+
+// Next implements EndpointStrategy.
+func (PriorityFallback) Next(n, current int, failed bool) int {
+	if current < 0 || !failed {
+		return 0
+	}
+	return (current + 1) % n
+}
+
+// WithEndpointStrategy sets the strategy DialCluster uses to rotate through
+// its endpoints. Defaults to RoundRobin.
+func WithEndpointStrategy(strategy EndpointStrategy) Option {
+	return func(o *options) {
+		o.endpointStrategy = strategy
+	}
+}
+
+// WithReturnToPreferred makes a cluster connection check every interval
+// whether the first endpoint accepts TCP connections while connected to
+// another one. If so, the connection is closed and re-established to the first
+// endpoint, whatever the strategy.
+func WithReturnToPreferred(interval time.Duration) Option {
+	return func(o *options) {
+		o.returnToPreferred = interval
+	}
+}
+
+type cluster struct {
+	endpoints []string
+	strategy  EndpointStrategy
+	dial      func(url string) (*amqp.Connection, error)
+	probe     func(url string) bool
+	current   int
+	failed    bool
+	returning bool // dial the first endpoint next, skipping the strategy
+}
+
+func (cl *cluster) connect() (*amqp.Connection, string, error) {
+	if len(cl.endpoints) == 0 {
+		return nil, "", errNoEndpoints
+	}
+	if cl.returning {
+		cl.returning = false
+		cl.current = 0
+	} else {
+		cl.current = cl.strategy.Next(len(cl.endpoints), cl.current, cl.failed)
+	}
+	endpoint := cl.endpoints[cl.current]
+
+	conn, err := cl.dial(endpoint)
+	cl.failed = err != nil
+	return conn, redactURL(endpoint), err
+}
+
+// preferredReachable reports whether the first endpoint accepts TCP
+// connections. It neither loads credentials nor TLS files, which dialing
+// would.
+func (cl *cluster) preferredReachable() bool {
+	return cl.probe(cl.endpoints[0])
+}
+
+// returnToPreferred makes the next connect dial the first endpoint.
+func (cl *cluster) returnToPreferred() {
+	cl.returning = true
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
@@ -1,24 +1,33 @@
 package chamqp
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
-	"math"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 const (
-	initialInterval      = 1 * time.Second
-	maxInterval          = 10 * time.Second
-	multiplier           = float64(2)
-	maxAttemps           = 10
-	AllowSelfTermination = true
+	initialInterval = 1 * time.Second
+	maxInterval     = 10 * time.Second
+	multiplier      = float64(2)
+	maxAttemps      = 10
+
+	defaultHeartbeat         = 10 * time.Second
+	defaultConnectionTimeout = 30 * time.Second
+	defaultLocale            = "en_US"
 )
 
+// AllowSelfTermination lets a Connection without a WithGiveUp callback
+// terminate the process once its ReconnectPolicy gives up. By default the
+// Connection is closed instead and Err reports why.
+var AllowSelfTermination = false
+
 // Connection manages the serialization and deserialization of frames from IO
 // and dispatches the frames to the appropriate channel. All RPC methods and
 // asynchronous Publishing, Delivery, Ack, Nack and Return messages are
@@ -27,87 +36,112 @@ const (
 type Connection struct {
 	conn                   *amqp.Connection
 	channels               []*Channel
-	errorChans             []chan error
+	errorSubs              subscribers[error]
 	shutdownChan, doneChan chan struct{}
+	shutdownOnce           sync.Once
 	mu                     sync.Mutex
+	statusMu               sync.Mutex // guards what State, Endpoint, Err and Status read, never held during I/O
+	opts                   options
+	err                    error
+	state                  StateChange
+	stateSubs              subscribers[StateChange]
+	endpoint               string
+	cluster                *cluster
+	connectedAt            time.Time
+	lastConnectedAt        time.Time
+	connections            int
+	blocked                blockedState
+	tlsConfig              *tls.Config
+	ready                  readiness
+	topologyErr            error
+	draining               atomic.Bool
 }
 
-// Dial accepts a string in the AMQP URI format and returns a new Connection
-// over TCP using PlainAuth. Defaults to a server heartbeat interval of 10
-// seconds and sets the handshake deadline to 30 seconds. After handshake,
-// deadlines are cleared.
-//
-// Use `NotifyError` to register a receiver for errors on the connection.
-func Dial(url string) *Connection {
-	conn := &Connection{
-		shutdownChan: make(chan struct{}),
-		doneChan:     make(chan struct{}),
-	}
-	connector := func() (*amqp.Connection, error) {
-		return amqp.Dial(url)
-	}
-	go conn.supervise(connector)
-	return conn
-}
-
-func DialTLS(url string, config *tls.Config) *Connection {
-	conn := &Connection{
+func newConnection(opts []Option) *Connection {
+	return &Connection{
 		shutdownChan: make(chan struct{}),
 		doneChan:     make(chan struct{}),
+		opts:         newOptions(opts),
+		blocked:      newBlockedState(),
 	}
-	connector := func() (*amqp.Connection, error) {
-		return amqp.DialTLS(url, config)
-	}
-	go conn.supervise(connector)
-	return conn
 }
 
-func DialBlocked(url string) (*Connection, error) {
-	conn := &Connection{
-		shutdownChan: make(chan struct{}),
-		doneChan:     make(chan struct{}),
-	}
-	connector := func() (*amqp.Connection, error) {
-		return amqp.Dial(url)
-	}
-	err := conn.connect(connector)
-	return conn, err
-}
+// connectOnce connects without a supervisor, so the connection is not
+// restored once it drops.
+func (c *Connection) connectOnce(connector func() (*amqp.Connection, error)) error {
+	close(c.doneChan)
 
-func DialTLSBlocked(url string, config *tls.Config) (*Connection, error) {
-	conn := &Connection{
-		shutdownChan: make(chan struct{}),
-		doneChan:     make(chan struct{}),
-	}
-	connector := func() (*amqp.Connection, error) {
-		return amqp.DialTLS(url, config)
+	err := c.connect(connector)
+	if err != nil {
+		c.updateState(StateDisconnected, 1, err)
+		return err
 	}
-	err := conn.connect(connector)
-	return conn, err
-}
 
-func (c *Connection) ConnectionState() tls.ConnectionState {
-	return c.conn.ConnectionState()
+	c.mu.Lock()
+	notifyClose := c.conn.NotifyClose(make(chan *amqp.Error, 1))
+	c.mu.Unlock()
+	go func() {
+		var err error
+		if amqpErr := <-notifyClose; amqpErr != nil {
+			err = amqpErr
+		}
+		c.disconnect(err)
+	}()
+	return nil
 }
 
 func (c *Connection) connect(connector func() (*amqp.Connection, error)) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+	// Dialing may take long; readers of the state must not wait for it.
 	conn, err := connector()
 	if err != nil {
 		return err
 	}
 
-	for _, ctx := range c.channels {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setState(StateConnected, c.currentState().Attempt, nil)
+	c.tlsConfig = c.opts.config.TLSClientConfig
+	if c.opts.tls != nil {
+		c.tlsConfig = c.opts.tls.lastConfig()
+	}
+	go c.watchBlocked(conn.NotifyBlocked(make(chan amqp.Blocking, 1)))
+
+	c.opts.logger.Info("connected", "endpoint", c.Endpoint())
+
+	start := time.Now()
+	for i, ctx := range c.channels {
 		chanErr := ctx.connected(conn)
 		if chanErr != nil {
-			fmt.Println("error during channel (re)construction")
+			c.opts.logger.Error("error during channel (re)construction", "channel", i, "error", chanErr)
+			c.statusMu.Lock()
+			c.topologyErr = chanErr
+			c.statusMu.Unlock()
+			conn.Close()
+			// Channels replayed so far must not look ready on the
+			// closed connection.
+			for _, ch := range c.channels {
+				ch.disconnected()
+			}
 			return chanErr
 		}
 	}
 
+	replay := time.Since(start)
+	c.opts.metrics.ObserveHistogram(MetricTopologyReplaySeconds, replay.Seconds(), nil)
+	c.statusMu.Lock()
+	if c.connections > 0 {
+		c.opts.metrics.AddCounter(MetricReconnects, 1, nil)
+	}
+	c.connections++
+	c.connectedAt = time.Now()
+	c.lastConnectedAt = c.connectedAt
+	c.topologyErr = nil
+	c.statusMu.Unlock()
+
 	c.conn = conn
+	c.setState(StateTopologyApplied, 0, nil)
+	c.opts.logger.Debug("topology applied", "channels", len(c.channels), "duration", replay)
 
 	return nil
 }
@@ -117,40 +151,49 @@ func (c *Connection) disconnect(err error) {
 	defer c.mu.Unlock()
 
 	if err != nil {
-		for _, c := range c.errorChans {
-			c <- err
-		}
+		c.opts.logger.Warn("connection lost", "endpoint", c.Endpoint(), "error", err)
+		c.errorSubs.send(err)
 	}
 
 	c.conn = nil
+	// A new connection starts unblocked.
+	c.blocked.set(amqp.Blocking{Active: false})
+	c.statusMu.Lock()
+	c.opts.metrics.ObserveHistogram(MetricConnectedSeconds, time.Since(c.connectedAt).Seconds(), nil)
+	c.connectedAt = time.Time{}
+	c.statusMu.Unlock()
 
 	for _, ctx := range c.channels {
 		ctx.disconnected()
 	}
+
+	c.setState(StateDisconnected, 0, err)
 }
 
 func (c *Connection) supervise(connector func() (*amqp.Connection, error)) {
-	var attempt float64
-
-	defer close(c.doneChan)
-
-	for {
-		backoffDelay := time.Duration(math.Pow(multiplier, attempt)) * initialInterval
-		if backoffDelay > maxInterval {
-			backoffDelay = maxInterval
+	var failures int
+	var gaveUp error
+
+	defer func() {
+		close(c.doneChan)
+		// Only now, so the callback may call Close.
+		if gaveUp != nil {
+			c.notifyGiveUp(gaveUp)
 		}
+	}()
 
+	for {
 		err := c.connect(connector)
 		if err != nil {
-			fmt.Println("Attempt is", attempt, "back off delay", backoffDelay)
-			for _, c := range c.errorChans {
-				c <- err
-			}
-			attempt++
-			if AllowSelfTermination && attempt >= maxAttemps {
-				fmt.Println("Too many errors, killing process")
-				os.Exit(1)
+			c.errorSubs.send(err)
+			failures++
+			backoffDelay, ok := c.opts.reconnectPolicy.NextBackoff(failures)
+			if !ok {
+				gaveUp = c.giveUp(failures, err)
+				return
 			}
+			c.updateState(StateReconnecting, failures, err)
+			c.opts.logger.Warn("connection attempt failed", "attempt", failures, "backoff", backoffDelay, "endpoint", c.Endpoint(), "error", err)
 			select {
 			case <-time.After(backoffDelay):
 				continue
@@ -158,43 +201,153 @@ func (c *Connection) supervise(connector func() (*amqp.Connection, error)) {
 				return
 			}
 		}
-		attempt = 0
+		failures = 0
 
-		notifyClose := make(chan *amqp.Error)
+		notifyClose := make(chan *amqp.Error, 1)
 		c.conn.NotifyClose(notifyClose)
+		if !c.watch(notifyClose) {
+			return
+		}
+		c.updateState(StateReconnecting, 0, nil)
+	}
+}
 
+// watch serves the established connection until it closes. It returns false
+// once supervision stops.
+func (c *Connection) watch(notifyClose <-chan *amqp.Error) bool {
+	preferredCheck, stopPreferredCheck := ticker(c.preferredInterval())
+	defer stopPreferredCheck()
+	tlsCheck, stopTLSCheck := ticker(c.tlsWatchInterval())
+	defer stopTLSCheck()
+	var tokenRefresh <-chan time.Time
+	var tokenTimer *time.Timer
+	if delay, ok := c.tokenRefreshIn(); ok {
+		tokenTimer = time.NewTimer(delay)
+		defer tokenTimer.Stop()
+		tokenRefresh = tokenTimer.C
+	}
+
+	// closeReason explains closes initiated by the supervisor itself.
+	var closeReason error
+	for {
 		select {
-		case err := <-notifyClose:
+		case amqpErr := <-notifyClose:
+			// Closing may end with an error of its own, like EOF once the
+			// server dropped the socket; the reason for closing matters.
+			err := closeReason
+			if err == nil && amqpErr != nil {
+				err = amqpErr
+			}
 			c.disconnect(err)
+			return true
+		case <-preferredCheck:
+			if c.cluster.preferredReachable() {
+				c.opts.logger.Info("preferred endpoint reachable again, reconnecting")
+				// Closing makes notifyClose fire and the next
+				// connect dial the preferred endpoint.
+				c.cluster.returnToPreferred()
+				c.conn.Close()
+			}
+		case <-tlsCheck:
+			if c.opts.tls.changed() {
+				c.opts.logger.Info("TLS files changed, reconnecting")
+				c.conn.Close()
+			}
+		case <-tokenRefresh:
+			err := c.refreshToken()
+			if err != nil {
+				c.opts.logger.Warn("token refresh failed, reconnecting", "error", err)
+				closeReason = err
+				c.conn.Close()
+			} else if delay, ok := c.tokenRefreshIn(); ok {
+				tokenTimer.Reset(delay)
+			}
 		case <-c.shutdownChan:
-			return
+			return false
 		}
 	}
 }
 
-// NotifyError registers a listener for error events either initiated by an
-// connect or close.
-func (c *Connection) NotifyError(receiver chan error) chan error {
+// ticker returns the channel of a ticker firing every interval and a func
+// stopping it. Without an interval the channel is nil and never fires.
+func ticker(interval time.Duration) (<-chan time.Time, func()) {
+	if interval <= 0 {
+		return nil, func() {}
+	}
+	t := time.NewTicker(interval)
+	return t.C, t.Stop
+}
+
+// preferredInterval is how often a cluster connection probes whether it can
+// return to its first endpoint, or 0 if it is connected to it already.
+func (c *Connection) preferredInterval() time.Duration {
+	if c.cluster == nil || c.cluster.current == 0 {
+		return 0
+	}
+	return c.opts.returnToPreferred
+}
+
+// giveUp closes the connection for good and returns the terminal error.
+func (c *Connection) giveUp(failures int, lastErr error) error {
+	err := fmt.Errorf("%w: %v", ErrReconnectGaveUp, lastErr)
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.statusMu.Lock()
+	c.err = err
+	c.statusMu.Unlock()
+	c.setState(StateClosed, failures, err)
+	c.mu.Unlock()
+
+	c.opts.logger.Error("giving up reconnecting", "attempt", failures, "error", lastErr)
+	return err
+}
+
+// notifyGiveUp runs the WithGiveUp callback once the supervisor stopped.
+func (c *Connection) notifyGiveUp(err error) {
+	if c.opts.onGiveUp != nil {
+		c.opts.onGiveUp(err)
+	} else if AllowSelfTermination {
+		c.opts.logger.Error("too many errors, killing process")
+		os.Exit(1)
+	}
+}
+
+// Err returns the terminal error once the ReconnectPolicy gave up, nil
+// otherwise.
+func (c *Connection) Err() error {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.err
+}
 
-	c.errorChans = append(c.errorChans, receiver)
+// NotifyError registers a listener for error events either initiated by an
+// connect or close. Sends never block the connection, errors that do not fit
+// into the receiver are dropped; use a buffered channel or SubscribeErrors.
+func (c *Connection) NotifyError(receiver chan error) chan error {
+	c.errorSubs.add(receiver, DropNewest)
 
 	return receiver
 }
 
+// SubscribeErrors registers a listener for error events like NotifyError.
+// The overflow policy decides which error is dropped once the receiver is
+// full. The returned Subscription counts dropped errors and unsubscribes the
+// receiver.
+func (c *Connection) SubscribeErrors(receiver chan error, overflow OverflowPolicy) *Subscription {
+	return c.errorSubs.add(receiver, overflow)
+}
+
 // Channel opens a unique, concurrent server channel to process the bulk of AMQP
 // messages. Any error from methods on this receiver will cause the Channel to
-// recreate itself.
-// Note that a channel should not be used from multiple goroutines as it is not
-// thread safe.
+// recreate itself. Use Channel.Close to dispose of it.
 func (c *Connection) Channel() *Channel {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	ch := &Channel{}
+	ch := &Channel{conn: c, logger: c.opts.logger, metrics: c.opts.metrics, propagator: c.opts.propagator}
 
-	c.channels = append(c.channels, ch)
+	c.addChannel(ch)
 
 	if c.conn != nil {
 		ch.connected(c.conn)
@@ -207,10 +360,10 @@ func (c *Connection) ChannelWithConfirm(noWait bool) *Channel {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	ch := &Channel{}
+	ch := &Channel{conn: c, logger: c.opts.logger, metrics: c.opts.metrics, propagator: c.opts.propagator}
 	ch.confirm = true
 	ch.confirmNoWait = noWait
-	c.channels = append(c.channels, ch)
+	c.addChannel(ch)
 
 	if c.conn != nil {
 		ch.connected(c.conn)
@@ -219,24 +372,126 @@ func (c *Connection) ChannelWithConfirm(noWait bool) *Channel {
 	return ch
 }
 
-// Close requests and waits for the response to close the AMQP connection.
-func (c *Connection) Close() error {
+// channelFailed marks ch as disconnected if channel is still its current
+// AMQP channel.
+func (c *Connection) channelFailed(ch *Channel, channel *amqp.Channel) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !ch.ch.CompareAndSwap(channel, nil) {
+		return false
+	}
+	ch.ready.set(false)
+	ch.deliveries.reset()
+	return true
+}
+
+// restoreChannel reopens ch on conn and replays its topology. It reports
+// restored=true without doing anything if conn was replaced in the meantime
+// or ch was closed, since there is nothing left to restore.
+func (c *Connection) restoreChannel(ch *Channel, conn *amqp.Connection) (restored bool, err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.conn != conn || !c.hasChannel(ch) {
+		return true, nil
+	}
+	err = ch.connected(conn)
+	if err != nil {
+		if channel := ch.ch.Swap(nil); channel != nil {
+			channel.Close()
+		}
+		ch.ready.set(false)
+		return false, err
+	}
+	c.opts.metrics.AddCounter(MetricChannelRecoveries, 1, nil)
+	ch.Logger().Info("channel restored")
+	return true, nil
+}
+
+func (c *Connection) hasChannel(ch *Channel) bool {
+	for _, candidate := range c.channels {
+		if candidate == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// addChannel must be called with c.mu held. Status reads the channels
+// under c.statusMu only.
+func (c *Connection) addChannel(ch *Channel) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.channels = append(c.channels, ch)
+}
+
+func (c *Connection) removeChannel(ch *Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	for i, candidate := range c.channels {
+		if candidate == ch {
+			c.channels = append(c.channels[:i:i], c.channels[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close requests and waits for the response to close the AMQP connection.
+func (c *Connection) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext stops supervision and closes the AMQP connection together with
+// all of its channels and consumers. It returns ctx.Err() if the supervisor
+// does not stop in time, for example while it is dialing, and closes the
+// connection in the background once it did; if ctx has a deadline it also
+// bounds the close handshake with the server.
+func (c *Connection) CloseContext(ctx context.Context) error {
+	c.stopSupervision()
+
 	select {
-	case <-c.shutdownChan:
-		// Already closed. Nothing to do.
-	default:
-		close(c.shutdownChan)
+	case <-c.doneChan:
+	case <-ctx.Done():
+		go c.closeWhenStopped()
+		return ctx.Err()
 	}
 
+	c.updateState(StateClosed, 0, nil)
+	return c.closeConn(ctx)
+}
+
+// closeWhenStopped closes the connection once the supervisor stopped, for
+// callers that gave up waiting for it.
+func (c *Connection) closeWhenStopped() {
 	<-c.doneChan
+	c.updateState(StateClosed, 0, nil)
+	c.closeConn(context.Background())
+}
 
-	if c.conn != nil {
-		conn := c.conn
-		c.conn = nil
-		return conn.Close()
+// stopSupervision tells the supervisor to stop. It does not take c.mu, which
+// the supervisor holds while connecting.
+func (c *Connection) stopSupervision() {
+	c.shutdownOnce.Do(func() {
+		close(c.shutdownChan)
+	})
+}
+
+func (c *Connection) closeConn(ctx context.Context) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
 	}
-	return nil
+	if deadline, ok := ctx.Deadline(); ok {
+		return conn.CloseDeadline(deadline)
+	}
+	return conn.Close()
 }
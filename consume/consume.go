@@ -21,11 +21,12 @@ var Defaults = chamqp.ConsumeSpec{
 func Consume(queueName string) QueueDecl {
 	consumeDecl := Defaults
 	consumeDecl.Queue = queueName
-	return QueueDecl{consumeDecl}
+	return QueueDecl{consumeSpec: consumeDecl}
 }
 
 type QueueDecl struct {
 	consumeSpec chamqp.ConsumeSpec
+	prefetch    *int
 }
 
 func (q QueueDecl) WithDeliveryChan(deliveryChan chan amqp.Delivery) DeliveryChan {
@@ -144,10 +145,24 @@ type ErrorChan struct {
 	queueDecl QueueDecl
 }
 
+// WithPrefetch makes Build set the prefetch count of the channel before
+// consuming, see chamqp.Channel.Qos. It is not part of the spec returned by
+// BuildSpec.
+func (e ErrorChan) WithPrefetch(prefetchCount int) ErrorChan {
+	e.queueDecl.prefetch = &prefetchCount
+	return e
+}
+
 func (e ErrorChan) BuildSpec() chamqp.ConsumeSpec {
 	return e.queueDecl.consumeSpec
 }
 
 func (e ErrorChan) Build(ch *chamqp.Channel) {
+	if e.queueDecl.prefetch != nil {
+		err := ch.Qos(*e.queueDecl.prefetch, 0, false)
+		if err != nil && e.queueDecl.consumeSpec.ErrorChan != nil {
+			e.queueDecl.consumeSpec.ErrorChan <- err
+		}
+	}
 	ch.ConsumeWithSpec(e.queueDecl.consumeSpec)
 }
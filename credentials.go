@@ -0,0 +1,100 @@
+package chamqp
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Credentials are used for PLAIN authentication.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// String redacts the password.
+func (c Credentials) String() string {
+	return c.Username + ":xxxxx"
+}
+
+// GoString redacts the password.
+func (c Credentials) GoString() string {
+	return fmt.Sprintf("chamqp.Credentials{Username:%q, Password:\"xxxxx\"}", c.Username)
+}
+
+// LogValue redacts the password.
+func (c Credentials) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
+
+// CredentialsProvider is consulted before every connection attempt, so
+// rotated passwords are picked up on reconnect.
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// CredentialsProviderFunc adapts a function to CredentialsProvider.
+type CredentialsProviderFunc func() (Credentials, error)
+
+// Credentials implements CredentialsProvider.
+func (f CredentialsProviderFunc) Credentials() (Credentials, error) {
+	return f()
+}
+
+// WithCredentialsProvider authenticates every connection attempt with the
+// credentials returned by provider. They take precedence over credentials in
+// the URI and over amqp.Config.SASL. It replaces WithTokenSource.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *options) {
+		o.credentials = provider
+		o.tokens = nil
+	}
+}
+
+// FileCredentials reads username and password from files, for example a
+// Kubernetes secret mounted as a volume. Trailing line breaks are removed.
+type FileCredentials struct {
+	UsernameFile string
+	PasswordFile string
+}
+
+// Credentials implements CredentialsProvider.
+func (f FileCredentials) Credentials() (Credentials, error) {
+	username, err := readSecretFile(f.UsernameFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	password, err := readSecretFile(f.PasswordFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{username, password}, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading credentials file %s: %w", path, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// EnvCredentials reads username and password from environment variables.
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+// Credentials implements CredentialsProvider.
+func (e EnvCredentials) Credentials() (Credentials, error) {
+	username, ok := os.LookupEnv(e.UsernameVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("environment variable %s not set", e.UsernameVar)
+	}
+	password, ok := os.LookupEnv(e.PasswordVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("environment variable %s not set", e.PasswordVar)
+	}
+	return Credentials{username, password}, nil
+}
@@ -0,0 +1,83 @@
+package chamqp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentials(t *testing.T) {
+	t.Run("password is redacted", func(t *testing.T) {
+		credentials := Credentials{"user", "secret"}
+		assert.NotContains(t, fmt.Sprint(credentials), "secret")
+		assert.NotContains(t, fmt.Sprintf("%#v", credentials), "secret")
+		assert.NotContains(t, credentials.LogValue().String(), "secret")
+	})
+}
+
+func TestFileCredentials(t *testing.T) {
+	t.Run("reads and trims the secret files", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "username"), []byte("user\n"), 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("secret"), 0o600))
+
+		credentials, err := FileCredentials{filepath.Join(dir, "username"), filepath.Join(dir, "password")}.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, Credentials{"user", "secret"}, credentials)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := FileCredentials{"/does/not/exist", "/does/not/exist"}.Credentials()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Run("reads the variables", func(t *testing.T) {
+		t.Setenv("CHAMQP_TEST_USER", "user")
+		t.Setenv("CHAMQP_TEST_PASSWORD", "secret")
+
+		credentials, err := EnvCredentials{"CHAMQP_TEST_USER", "CHAMQP_TEST_PASSWORD"}.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, Credentials{"user", "secret"}, credentials)
+	})
+
+	t.Run("unset variable", func(t *testing.T) {
+		_, err := EnvCredentials{"CHAMQP_TEST_UNSET", "CHAMQP_TEST_UNSET"}.Credentials()
+		assert.EqualError(t, err, "environment variable CHAMQP_TEST_UNSET not set")
+	})
+}
+
+func TestWithCredentialsProvider(t *testing.T) {
+	t.Run("the provider is consulted on every attempt", func(t *testing.T) {
+		calls := 0
+		gaveUp := make(chan error, 1)
+		conn := DialConfig(unreachableURL, amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return nil, errors.New("refused")
+			},
+		},
+			WithCredentialsProvider(CredentialsProviderFunc(func() (Credentials, error) {
+				calls++
+				if calls == 3 {
+					return Credentials{}, errors.New("secret not mounted")
+				}
+				return Credentials{"user", "secret"}, nil
+			})),
+			WithReconnectPolicy(ExponentialBackoff{InitialInterval: time.Millisecond, MaxAttempts: 3}),
+			WithGiveUp(func(err error) { gaveUp <- err }),
+		)
+
+		err := <-gaveUp
+		assert.Equal(t, 3, calls)
+		assert.ErrorContains(t, err, "chamqp: loading credentials: secret not mounted")
+		assert.NoError(t, conn.Close())
+	})
+}
@@ -0,0 +1,187 @@
+package chamqp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Dial accepts a string in the AMQP URI format and returns a new Connection
+// over TCP using PlainAuth. Defaults to a server heartbeat interval of 10
+// seconds and sets the handshake deadline to 30 seconds. After handshake,
+// deadlines are cleared.
+//
+// Use `NotifyError` to register a receiver for errors on the connection and
+// `WithReconnectPolicy` to control the back-off between connection attempts.
+func Dial(url string, opts ...Option) *Connection {
+	return dial(context.Background(), []string{url}, opts)
+}
+
+// DialTLS is like Dial but uses config for amqps:// URIs.
+func DialTLS(url string, config *tls.Config, opts ...Option) *Connection {
+	return dial(context.Background(), []string{url}, append(opts[:len(opts):len(opts)], WithTLSConfig(config)))
+}
+
+// DialConfig is like Dial but connects with the given amqp.Config, which
+// controls heartbeat, vhost, frame size, channel max, locale, SASL mechanisms,
+// client properties and TLS.
+func DialConfig(url string, config amqp.Config, opts ...Option) *Connection {
+	return dial(context.Background(), []string{url}, append([]Option{WithConfig(config)}, opts...))
+}
+
+// DialContext is like Dial but stops supervision and closes the connection
+// once ctx is done. Connection attempts in flight are aborted as well.
+func DialContext(ctx context.Context, url string, opts ...Option) *Connection {
+	conn := dial(ctx, []string{url}, opts)
+	go conn.closeOnDone(ctx)
+	return conn
+}
+
+// DialCluster is like Dial but rotates through the given node URIs on
+// reconnect according to the EndpointStrategy. The endpoint currently in use
+// is reported by Connection.Endpoint and in every StateChange.
+func DialCluster(urls []string, opts ...Option) *Connection {
+	return dial(context.Background(), urls, opts)
+}
+
+// DialBlocked connects before returning and does not restore the connection
+// once it drops.
+func DialBlocked(url string, opts ...Option) (*Connection, error) {
+	return dialBlocked([]string{url}, opts)
+}
+
+// DialTLSBlocked is like DialBlocked but uses config for amqps:// URIs.
+func DialTLSBlocked(url string, config *tls.Config, opts ...Option) (*Connection, error) {
+	return dialBlocked([]string{url}, append(opts[:len(opts):len(opts)], WithTLSConfig(config)))
+}
+
+// DialConfigBlocked is like DialBlocked but connects with the given
+// amqp.Config.
+func DialConfigBlocked(url string, config amqp.Config, opts ...Option) (*Connection, error) {
+	return dialBlocked([]string{url}, append([]Option{WithConfig(config)}, opts...))
+}
+
+// WithConfig sets the amqp.Config used for every connection attempt. It
+// replaces the defaults of a 10 second heartbeat and the en_US locale. If
+// config.Dial is nil, connecting times out after 30 seconds.
+func WithConfig(config amqp.Config) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for amqps:// URIs. It
+// replaces WithTLSFiles and WithGetTLSConfig.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.config.TLSClientConfig = config
+		o.tls = nil
+	}
+}
+
+func defaultConfig() amqp.Config {
+	return amqp.Config{
+		Heartbeat: defaultHeartbeat,
+		Locale:    defaultLocale,
+	}
+}
+
+func dial(ctx context.Context, urls []string, opts []Option) *Connection {
+	conn := newConnection(opts)
+	conn.cluster = newCluster(ctx, urls, conn.opts)
+	go conn.supervise(conn.connector())
+	return conn
+}
+
+func dialBlocked(urls []string, opts []Option) (*Connection, error) {
+	conn := newConnection(opts)
+	conn.cluster = newCluster(context.Background(), urls, conn.opts)
+	err := conn.connectOnce(conn.connector())
+	return conn, err
+}
+
+func newCluster(ctx context.Context, urls []string, opts options) *cluster {
+	config := opts.config
+	if config.Dial == nil {
+		config.Dial = contextDialer(ctx, defaultConnectionTimeout)
+	}
+	return &cluster{
+		endpoints: urls,
+		strategy:  opts.endpointStrategy,
+		dial: func(url string) (*amqp.Connection, error) {
+			config := config
+			if opts.tls != nil {
+				tlsConfig, err := opts.tls.config()
+				if err != nil {
+					return nil, err
+				}
+				config.TLSClientConfig = tlsConfig
+			}
+			if opts.credentials != nil {
+				credentials, err := opts.credentials.Credentials()
+				if err != nil {
+					return nil, fmt.Errorf("chamqp: loading credentials: %w", err)
+				}
+				config.SASL = []amqp.Authentication{&amqp.PlainAuth{
+					Username: credentials.Username,
+					Password: credentials.Password,
+				}}
+			}
+			return amqp.DialConfig(url, config)
+		},
+		probe: func(url string) bool {
+			uri, err := amqp.ParseURI(url)
+			if err != nil {
+				return false
+			}
+			conn, err := config.Dial("tcp", net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)))
+			if err != nil {
+				return false
+			}
+			conn.Close()
+			return true
+		},
+		current: -1,
+	}
+}
+
+// connector dials the next endpoint of the cluster. Only the supervisor
+// calls it, without c.mu held.
+func (c *Connection) connector() func() (*amqp.Connection, error) {
+	return func() (*amqp.Connection, error) {
+		amqpConn, endpoint, err := c.cluster.connect()
+		c.setEndpoint(endpoint)
+		return amqpConn, err
+	}
+}
+
+func contextDialer(ctx context.Context, connectionTimeout time.Duration) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		dialer := net.Dialer{Timeout: connectionTimeout}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		// Same as amqp.DefaultDial, the deadline covers the TLS and AMQP
+		// handshake and is cleared once the connection is established.
+		if err := conn.SetDeadline(time.Now().Add(connectionTimeout)); err != nil {
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+func (c *Connection) closeOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		c.Close()
+	case <-c.doneChan:
+	}
+}
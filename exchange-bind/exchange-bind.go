@@ -0,0 +1,120 @@
+package exchange_bind
+
+import (
+	"github.com/Contargo/chamqp"
+	queue_bind "github.com/Contargo/chamqp/queue-bind"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var Defaults = chamqp.ExchangeBindSpec{
+	Destination: "",
+	Key:         "",
+	Source:      "",
+	NoWait:      false,
+	Args:        nil,
+	ErrorChan:   nil,
+}
+
+func BindExchange(destination string) DestinationDecl {
+	bind := Defaults
+	bind.Destination = destination
+	return DestinationDecl{bind}
+}
+
+func BindExchangeWithSource(destination, source string) SourceDecl {
+	bind := Defaults
+	bind.Destination = destination
+	bind.Source = source
+	return SourceDecl{DestinationDecl{bind}}
+}
+
+type DestinationDecl struct {
+	exchangeBindSpec chamqp.ExchangeBindSpec
+}
+
+func (d DestinationDecl) WithSource(source string) SourceDecl {
+	d.exchangeBindSpec.Source = source
+	return SourceDecl{d}
+}
+
+type SourceDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (s SourceDecl) WithRoutingKey(routingKey string) KeyDecl {
+	s.destinationDecl.exchangeBindSpec.Key = routingKey
+	return KeyDecl{s.destinationDecl}
+}
+
+type KeyDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (k KeyDecl) Defaults() ErrorChanDecl {
+	return ErrorChanDecl{k.destinationDecl}
+}
+
+func (k KeyDecl) WithNoWait(noWait bool) NoWaitDecl {
+	k.destinationDecl.exchangeBindSpec.NoWait = noWait
+	return NoWaitDecl{k.destinationDecl}
+}
+
+func (k KeyDecl) WithDefaultNoWait() NoWaitDecl {
+	return NoWaitDecl{k.destinationDecl}
+}
+
+type NoWaitDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (n NoWaitDecl) Defaults() ErrorChanDecl {
+	return ErrorChanDecl{n.destinationDecl}
+}
+
+func (n NoWaitDecl) WithArgs(args amqp.Table) ArgsDecl {
+	n.destinationDecl.exchangeBindSpec.Args = args
+	return ArgsDecl{n.destinationDecl}
+}
+
+func (n NoWaitDecl) WithDefaultArgs() ArgsDecl {
+	return ArgsDecl{n.destinationDecl}
+}
+
+type ArgsDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (a ArgsDecl) Defaults() ErrorChanDecl {
+	return ErrorChanDecl{a.destinationDecl}
+}
+
+func (a ArgsDecl) WithErrorChannel(channel chan error) ErrorChanDecl {
+	a.destinationDecl.exchangeBindSpec.ErrorChan = channel
+	return ErrorChanDecl{a.destinationDecl}
+}
+
+func (a ArgsDecl) WithDefaultErrorChannel() ErrorChanDecl {
+	return ErrorChanDecl{a.destinationDecl}
+}
+
+type ErrorChanDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (e ErrorChanDecl) BuildSpec() chamqp.ExchangeBindSpec {
+	return e.destinationDecl.exchangeBindSpec
+}
+
+type BindDecl struct {
+	destinationDecl DestinationDecl
+}
+
+func (e ErrorChanDecl) Build(channel *chamqp.Channel) BindDecl {
+	channel.ExchangeBindWithSpec(e.destinationDecl.exchangeBindSpec)
+	return BindDecl{e.destinationDecl}
+}
+
+// AndBindQueue continues with binding a queue to the destination exchange.
+func (b BindDecl) AndBindQueue(queueName string) queue_bind.ExchangeDecl {
+	return queue_bind.BindQueueWithExchange(queueName, b.destinationDecl.exchangeBindSpec.Destination)
+}
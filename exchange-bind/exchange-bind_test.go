@@ -0,0 +1,47 @@
+package exchange_bind
+
+import (
+	"github.com/Contargo/chamqp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCustomDeclare(t *testing.T) {
+	t.Run("fills decls correctly", func(t *testing.T) {
+		expectedSpec := chamqp.ExchangeBindSpec{
+			Destination: "domain",
+			Key:         "domain.#",
+			Source:      "central",
+			NoWait:      false,
+			Args:        nil,
+			ErrorChan:   nil,
+		}
+
+		r := BindExchange("domain").
+			WithSource("central").
+			WithRoutingKey("domain.#").
+			WithNoWait(false).
+			WithArgs(nil).
+			WithErrorChannel(nil).
+			BuildSpec()
+		assert.Equal(t, expectedSpec, r)
+	})
+}
+
+func TestWithDefaults(t *testing.T) {
+	t.Run("custom destination, source, routingkey", func(t *testing.T) {
+		expectedSpec := chamqp.ExchangeBindSpec{
+			Destination: "domain",
+			Key:         "routing",
+			Source:      "central",
+			NoWait:      false,
+			Args:        nil,
+			ErrorChan:   nil,
+		}
+		r := BindExchangeWithSource("domain", "central").
+			WithRoutingKey("routing").
+			Defaults().
+			BuildSpec()
+		assert.Equal(t, expectedSpec, r)
+	})
+}
@@ -2,6 +2,7 @@ package exchange_declare
 
 import (
 	"github.com/Contargo/chamqp"
+	exchange_bind "github.com/Contargo/chamqp/exchange-bind"
 	"github.com/Contargo/chamqp/queue-declaration"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -149,6 +150,11 @@ func (b BindDecl) AndDeclareQueue(queueName string) queue_declaration.NameDecl {
 	return queue_declaration.DeclareQueueWithChan(queueName, &b.nameDecl.exchangeDeclarationSpec.Name)
 }
 
+// AndBindToExchange continues with binding the declared exchange to source.
+func (b BindDecl) AndBindToExchange(source string) exchange_bind.SourceDecl {
+	return exchange_bind.BindExchangeWithSource(b.nameDecl.exchangeDeclarationSpec.Name, source)
+}
+
 func DeclareExchange(exchangeName string) NameDecl {
 	filledDefaults := Defaults
 	filledDefaults.Name = exchangeName
@@ -0,0 +1,13 @@
+package chamqp
+
+// NewFakeBroker lets the tests of the builder packages, which live in package
+// chamqp_test to avoid an import cycle, talk to the fake broker.
+var NewFakeBroker = newFakeBroker
+
+func (b *fakeBroker) URL() string {
+	return b.url()
+}
+
+func (b *fakeBroker) Methods() []string {
+	return b.methods()
+}
@@ -0,0 +1,135 @@
+// Package health serves liveness and readiness of a chamqp.Connection as
+// JSON, for example for Kubernetes probes.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Contargo/chamqp"
+)
+
+type handler struct {
+	conn        *chamqp.Connection
+	check       func(chamqp.Status) bool
+	okCode      int
+	failingCode int
+}
+
+// Option configures a handler.
+type Option func(*handler)
+
+// WithStatusCodes sets the HTTP status codes for a passing and a failing
+// check. They default to 200 and 503.
+func WithStatusCodes(ok, failing int) Option {
+	return func(h *handler) {
+		h.okCode = ok
+		h.failingCode = failing
+	}
+}
+
+// Liveness fails only once the connection is closed, either by Close or
+// because its ReconnectPolicy gave up. Reconnecting does not fail it.
+func Liveness(conn *chamqp.Connection, opts ...Option) http.Handler {
+	return newHandler(conn, func(status chamqp.Status) bool {
+		return status.State != chamqp.StateClosed
+	}, opts)
+}
+
+// Readiness passes while the connection is up with its topology applied and
+// all channels are ready.
+func Readiness(conn *chamqp.Connection, opts ...Option) http.Handler {
+	return newHandler(conn, func(status chamqp.Status) bool {
+		if status.State != chamqp.StateTopologyApplied {
+			return false
+		}
+		for _, channel := range status.Channels {
+			if !channel.Ready {
+				return false
+			}
+		}
+		return true
+	}, opts)
+}
+
+func newHandler(conn *chamqp.Connection, check func(chamqp.Status) bool, opts []Option) *handler {
+	h := &handler{
+		conn:        conn,
+		check:       check,
+		okCode:      http.StatusOK,
+		failingCode: http.StatusServiceUnavailable,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Report is the JSON body of both handlers.
+type Report struct {
+	Status                string          `json:"status"` // "ok" or "failing"
+	State                 string          `json:"state"`
+	Endpoint              string          `json:"endpoint,omitempty"`
+	ConnectedSince        *time.Time      `json:"connected_since,omitempty"`
+	SecondsSinceReconnect *float64        `json:"seconds_since_reconnect,omitempty"`
+	Reconnects            int             `json:"reconnects"`
+	TopologyError         string          `json:"topology_error,omitempty"`
+	Consumers             map[string]int  `json:"consumers"`
+	Channels              []ChannelReport `json:"channels"`
+}
+
+// ChannelReport describes one channel in a Report.
+type ChannelReport struct {
+	Ready     bool           `json:"ready"`
+	Confirm   bool           `json:"confirm"`
+	Consumers map[string]int `json:"consumers"`
+	Error     string         `json:"error,omitempty"`
+}
+
+func newReport(status chamqp.Status, ok bool, now time.Time) Report {
+	report := Report{
+		Status:     "ok",
+		State:      status.State.String(),
+		Endpoint:   status.Endpoint,
+		Reconnects: status.Reconnects,
+		Consumers:  status.Consumers,
+		Channels:   []ChannelReport{},
+	}
+	if !ok {
+		report.Status = "failing"
+	}
+	if !status.ConnectedSince.IsZero() {
+		report.ConnectedSince = &status.ConnectedSince
+	}
+	if !status.LastConnected.IsZero() {
+		// Also during an outage, telling how long it lasts.
+		since := now.Sub(status.LastConnected).Seconds()
+		report.SecondsSinceReconnect = &since
+	}
+	if status.TopologyErr != nil {
+		report.TopologyError = status.TopologyErr.Error()
+	}
+	for _, channel := range status.Channels {
+		channelReport := ChannelReport{Ready: channel.Ready, Confirm: channel.Confirm, Consumers: channel.Consumers}
+		if channel.Err != nil {
+			channelReport.Error = channel.Err.Error()
+		}
+		report.Channels = append(report.Channels, channelReport)
+	}
+	return report
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := h.conn.Status()
+	ok := h.check(status)
+
+	code := h.okCode
+	if !ok {
+		code = h.failingCode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(newReport(status, ok, time.Now()))
+}
@@ -1,7 +1,6 @@
 package heartbeatmanagement
 
 import (
-	"log"
 	"strconv"
 	"time"
 
@@ -34,9 +33,11 @@ func (h *HeartbeatSender[T]) StartSending() {
 }
 
 func (h *HeartbeatSender[T]) StartSendingWithParams(durable, autodelete, internal, nowait bool) {
+	logger := h.chamqpChannel.Logger().With("exchange", h.exchangeName, "key", h.routingKey)
+
 	interval, err := daemon.SdWatchdogEnabled(false)
 	if err != nil || interval == 0 {
-		log.Println("no interval found, defaulting")
+		logger.Info("no watchdog interval found, defaulting")
 		interval = 50 * time.Second * 20 
 	}
 
@@ -48,17 +49,19 @@ func (h *HeartbeatSender[T]) StartSendingWithParams(durable, autodelete, interna
 			Expiration: strconv.Itoa(2 * 60 * 1000),
 		})
 		if err == nil {
-			log.Println("published heartbeat", heartbeatObj)
+			logger.Debug("published heartbeat", "heartbeat", heartbeatObj)
 			notificationSupported, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog)
 			if !notificationSupported {
-				log.Println("Systemd watchdog notification not supported")
+				logger.Debug("systemd watchdog notification not supported")
 			} else {
 				if err != nil {
-					log.Println("err during sending out systemd notificaiton", err)
+					logger.Warn("err during sending out systemd notification", "error", err)
 				} else {
-					log.Println("Successfully watchdoged")
+					logger.Debug("successfully watchdoged")
 				}
 			}
+		} else {
+			logger.Warn("publishing heartbeat failed", "error", err)
 		}
 
 		time.Sleep(interval / 20)
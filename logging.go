@@ -0,0 +1,23 @@
+package chamqp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger sets the logger used by the Connection, its channels and the
+// helpers built on top of them. Without it chamqp logs nothing.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,148 @@
+package chamqp
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the metrics reported into Metrics.
+const (
+	MetricReconnects            = "chamqp_reconnects_total"
+	MetricChannelRecoveries     = "chamqp_channel_recoveries_total"
+	MetricConnectedSeconds      = "chamqp_connected_seconds"
+	MetricTopologyReplaySeconds = "chamqp_topology_replay_seconds"
+	MetricPublishes             = "chamqp_publishes_total"
+	MetricPublishErrors         = "chamqp_publish_errors_total"
+	MetricConfirmAcks           = "chamqp_confirm_acks_total"
+	MetricConfirmNacks          = "chamqp_confirm_nacks_total"
+	MetricReturns               = "chamqp_returns_total"
+	MetricDeliveries            = "chamqp_deliveries_total"
+	MetricShovelBufferOccupancy = "chamqp_shovel_buffer_occupancy"
+	MetricPoolUtilization       = "chamqp_channel_pool_utilization"
+)
+
+// Metrics receives operational numbers from a Connection, its channels and
+// their consumers. Implementations must be safe for concurrent use. Labels
+// may be nil.
+type Metrics interface {
+	// AddCounter increases the counter name by delta.
+	AddCounter(name string, delta int64, labels map[string]string)
+	// ObserveHistogram records value in the histogram name.
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// WithMetrics sets the Metrics the Connection and its channels report into.
+// Without it nothing is recorded.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) AddCounter(string, int64, map[string]string)         {}
+func (nopMetrics) ObserveHistogram(string, float64, map[string]string) {}
+
+// ExpvarMetrics publishes metrics as an expvar.Map. Counters are expvar.Int
+// values, histograms are summarized by count, sum, min and max. Labels are
+// appended to the key in the form name{key="value"}.
+type ExpvarMetrics struct {
+	mu     sync.Mutex
+	values *expvar.Map
+}
+
+// NewExpvarMetrics publishes a map with the given name, or reuses it if it
+// is already published.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	values, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		values = expvar.NewMap(name)
+	}
+	return &ExpvarMetrics{values: values}
+}
+
+// AddCounter implements Metrics.
+func (e *ExpvarMetrics) AddCounter(name string, delta int64, labels map[string]string) {
+	e.values.Add(metricKey(name, labels), delta)
+}
+
+// ObserveHistogram implements Metrics.
+func (e *ExpvarMetrics) ObserveHistogram(name string, value float64, labels map[string]string) {
+	key := metricKey(name, labels)
+
+	e.mu.Lock()
+	histogram, ok := e.values.Get(key).(*expvarHistogram)
+	if !ok {
+		histogram = &expvarHistogram{}
+		e.values.Set(key, histogram)
+	}
+	e.mu.Unlock()
+
+	histogram.observe(value)
+}
+
+func metricKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(labels[k])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+type expvarHistogram struct {
+	mu    sync.Mutex
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (h *expvarHistogram) observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.count++
+	h.sum += value
+}
+
+// String implements expvar.Var.
+func (h *expvarHistogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, _ := json.Marshal(struct {
+		Count int64   `json:"count"`
+		Sum   float64 `json:"sum"`
+		Min   float64 `json:"min"`
+		Max   float64 `json:"max"`
+	}{h.count, h.sum, h.min, h.max})
+	return string(b)
+}
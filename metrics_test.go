@@ -0,0 +1,44 @@
+package chamqp
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	t.Run("counters and histograms are keyed by labels", func(t *testing.T) {
+		metrics := NewExpvarMetrics("chamqp_test")
+		metrics.values.Init()
+		metrics.AddCounter(MetricPublishes, 2, map[string]string{"exchange": "events"})
+		metrics.AddCounter(MetricPublishes, 1, map[string]string{"exchange": "events"})
+		metrics.ObserveHistogram(MetricTopologyReplaySeconds, 0.5, nil)
+		metrics.ObserveHistogram(MetricTopologyReplaySeconds, 1.5, nil)
+
+		assert.Equal(t, "3", metrics.values.Get(`chamqp_publishes_total{exchange="events"}`).String())
+		assert.JSONEq(t, `{"count":2,"sum":2,"min":0.5,"max":1.5}`, metrics.values.Get(MetricTopologyReplaySeconds).String())
+	})
+
+	t.Run("the map is reused", func(t *testing.T) {
+		assert.Same(t, NewExpvarMetrics("chamqp_test").values, NewExpvarMetrics("chamqp_test").values)
+	})
+}
+
+func TestShovelMetrics(t *testing.T) {
+	t.Run("deliveries are counted per queue", func(t *testing.T) {
+		metrics := NewExpvarMetrics("chamqp_shovel_test")
+		metrics.values.Init()
+		src := make(chan amqp.Delivery, 2)
+		dest := make(chan amqp.Delivery, 2)
+		src <- amqp.Delivery{}
+		src <- amqp.Delivery{}
+		close(src)
+
+		shovel(src, dest, nil, metrics, map[string]string{"queue": "orders"})
+
+		assert.Len(t, dest, 2)
+		assert.Equal(t, "2", metrics.values.Get(`chamqp_deliveries_total{queue="orders"}`).String())
+		assert.JSONEq(t, `{"count":2,"sum":1,"min":0,"max":1}`, metrics.values.Get(`chamqp_shovel_buffer_occupancy{queue="orders"}`).String())
+	})
+}
@@ -0,0 +1,66 @@
+package chamqp
+
+import (
+	"log/slog"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Option configures a Connection at dial time.
+type Option func(*options)
+
+type options struct {
+	config      amqp.Config
+	credentials CredentialsProvider
+	tokens      *tokenCredentials
+	tls         *tlsSource
+
+	reconnectPolicy ReconnectPolicy
+	onGiveUp        func(err error)
+	logger          *slog.Logger
+	metrics         Metrics
+	propagator      Propagator
+
+	endpointStrategy  EndpointStrategy
+	returnToPreferred time.Duration
+
+	blockedMode       blockedMode
+	blockedTimeout    time.Duration
+	blockedBufferSize int
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		config: defaultConfig(),
+
+		reconnectPolicy: DefaultReconnectPolicy,
+		logger:          discardLogger,
+		metrics:         nopMetrics{},
+		propagator:      W3CPropagator{},
+
+		endpointStrategy: RoundRobin{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithReconnectPolicy replaces DefaultReconnectPolicy.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnectPolicy = policy
+	}
+}
+
+// WithGiveUp registers a callback invoked once the ReconnectPolicy gives up.
+// The callback receives the terminal error, which is also returned by
+// Connection.Err. It runs after supervision stopped, so it may close the
+// Connection. Setting a callback disables the process termination controlled
+// by AllowSelfTermination.
+func WithGiveUp(fn func(err error)) Option {
+	return func(o *options) {
+		o.onGiveUp = fn
+	}
+}
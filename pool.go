@@ -0,0 +1,191 @@
+package chamqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	// ErrPoolClosed is returned when borrowing from a closed ChannelPool.
+	ErrPoolClosed = errors.New("chamqp: channel pool closed")
+	// ErrNotConfirmed is returned by a ChannelPool in confirm mode if the
+	// broker nacked a publish or the channel closed before confirming it.
+	ErrNotConfirmed = errors.New("chamqp: publish not confirmed")
+)
+
+// ChannelPool spreads publishes from many goroutines over a fixed number of
+// channels of one Connection. Like any Channel, the pooled ones are restored
+// after reconnects.
+type ChannelPool struct {
+	channels []*Channel
+	idle     chan *Channel
+	confirm  bool
+	metrics  Metrics
+	inUse    atomic.Int64
+	waiting  atomic.Int64
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// PoolStats reports the utilization of a ChannelPool.
+type PoolStats struct {
+	Size    int // channels in the pool
+	InUse   int // channels currently borrowed
+	Waiting int // callers waiting for a channel
+}
+
+// ChannelPool opens a pool of size channels.
+func (c *Connection) ChannelPool(size int) *ChannelPool {
+	return newChannelPool(size, false, c.opts.metrics, c.Channel)
+}
+
+// ChannelPoolWithConfirm opens a pool of size channels in confirm mode. Its
+// publish methods return once the broker confirmed the message.
+func (c *Connection) ChannelPoolWithConfirm(size int, noWait bool) *ChannelPool {
+	return newChannelPool(size, true, c.opts.metrics, func() *Channel {
+		return c.ChannelWithConfirm(noWait)
+	})
+}
+
+func newChannelPool(size int, confirm bool, metrics Metrics, open func() *Channel) *ChannelPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &ChannelPool{
+		idle:    make(chan *Channel, size),
+		confirm: confirm,
+		metrics: metrics,
+		closed:  make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		ch := open()
+		p.channels = append(p.channels, ch)
+		p.idle <- ch
+	}
+	return p
+}
+
+// Get borrows a channel, waiting until one is returned if all are in use.
+// Return it with Put.
+func (p *ChannelPool) Get(ctx context.Context) (*Channel, error) {
+	select {
+	case <-p.closed:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	var ch *Channel
+	select {
+	case ch = <-p.idle:
+	default:
+		p.waiting.Add(1)
+		defer p.waiting.Add(-1)
+
+		select {
+		case ch = <-p.idle:
+		case <-p.closed:
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	inUse := p.inUse.Add(1)
+	p.metrics.ObserveHistogram(MetricPoolUtilization, float64(inUse)/float64(len(p.channels)), nil)
+	return ch, nil
+}
+
+// Put returns a channel borrowed with Get.
+func (p *ChannelPool) Put(ch *Channel) {
+	p.inUse.Add(-1)
+	p.idle <- ch
+}
+
+// Stats reports how many channels are borrowed and how many callers wait
+// for one.
+func (p *ChannelPool) Stats() PoolStats {
+	return PoolStats{
+		Size:    len(p.channels),
+		InUse:   int(p.inUse.Load()),
+		Waiting: int(p.waiting.Load()),
+	}
+}
+
+// Publish sends a Publishing on a borrowed channel.
+func (p *ChannelPool) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return p.PublishWithContext(context.Background(), exchange, key, mandatory, immediate, msg)
+}
+
+// PublishWithContext is like Publish but injects the trace context of ctx
+// into the message headers and stops waiting for a channel or a
+// confirmation once ctx is done.
+func (p *ChannelPool) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	ch, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	var sent chan *amqp.DeferredConfirmation
+	if p.confirm {
+		sent = make(chan *amqp.DeferredConfirmation, 1)
+	}
+	confirmation, err := ch.publishWithContext(ctx, pendingPublish{exchange, key, mandatory, immediate, msg, sent})
+	p.Put(ch)
+	if err != nil || !p.confirm {
+		return err
+	}
+	if confirmation == nil {
+		// Buffered while the connection is blocked.
+		select {
+		case confirmation = <-sent:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if confirmation == nil {
+			return ErrNotConfirmed
+		}
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+func (p *ChannelPool) PublishJSON(exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
+	return p.PublishJSONWithContext(context.Background(), exchange, key, mandatory, immediate, objectToBeSent)
+}
+
+// PublishJSONWithContext is like PublishJSON but injects the trace context
+// of ctx into the message headers.
+func (p *ChannelPool) PublishJSONWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, objectToBeSent interface{}) error {
+	payload, err := json.Marshal(objectToBeSent)
+	if err != nil {
+		return err
+	}
+	return p.PublishWithContext(ctx, exchange, key, mandatory, immediate, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        payload,
+	})
+}
+
+// Close closes all channels of the pool. Borrowed channels are closed too;
+// callers waiting in Get receive ErrPoolClosed.
+func (p *ChannelPool) Close() error {
+	var errs []error
+	p.once.Do(func() {
+		close(p.closed)
+		for _, ch := range p.channels {
+			errs = append(errs, ch.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
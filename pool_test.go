@@ -0,0 +1,127 @@
+package chamqp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelPool(t *testing.T) {
+	t.Run("borrow and return", func(t *testing.T) {
+		conn := newConnection(nil)
+		pool := conn.ChannelPool(2)
+
+		first, err := pool.Get(context.Background())
+		assert.NoError(t, err)
+		second, err := pool.Get(context.Background())
+		assert.NoError(t, err)
+		assert.NotSame(t, first, second)
+		assert.Equal(t, PoolStats{Size: 2, InUse: 2}, pool.Stats())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = pool.Get(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		pool.Put(first)
+		borrowed, err := pool.Get(context.Background())
+		assert.NoError(t, err)
+		assert.Same(t, first, borrowed)
+	})
+
+	t.Run("closing deregisters the channels", func(t *testing.T) {
+		conn := newConnection(nil)
+		pool := conn.ChannelPool(3)
+		assert.Len(t, conn.channels, 3)
+
+		assert.NoError(t, pool.Close())
+		assert.NoError(t, pool.Close())
+		assert.Empty(t, conn.channels)
+		_, err := pool.Get(context.Background())
+		assert.ErrorIs(t, err, ErrPoolClosed)
+	})
+
+	t.Run("confirmed publishes from many goroutines", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		pool := conn.ChannelPoolWithConfirm(3, false)
+		defer pool.Close()
+		assert.Eventually(t, func() bool { return conn.State() == StateTopologyApplied }, time.Second, time.Millisecond)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					assert.NoError(t, pool.PublishJSON("exchange", "key", false, false, j))
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(400), broker.publishes.Load())
+		assert.Equal(t, PoolStats{Size: 3}, pool.Stats())
+	})
+
+	t.Run("buffered publishes wait for their confirmation", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}), WithBlockedBuffer(1))
+		defer conn.Close()
+		pool := conn.ChannelPoolWithConfirm(1, false)
+		defer pool.Close()
+		waitReady(t, conn)
+		conn.blocked.set(amqp.Blocking{Active: true, Reason: "low on memory"})
+
+		published := make(chan error, 1)
+		go func() { published <- pool.Publish("exchange", "key", false, false, amqp.Publishing{}) }()
+		select {
+		case err := <-published:
+			t.Fatalf("returned before the publish was sent: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		conn.blocked.set(amqp.Blocking{Active: false})
+		select {
+		case err := <-published:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("not confirmed after unblocking")
+		}
+		assert.Equal(t, int64(1), broker.publishes.Load())
+	})
+
+	t.Run("dropped buffered publishes are not confirmed", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}), WithBlockedBuffer(1))
+		defer conn.Close()
+		pool := conn.ChannelPoolWithConfirm(1, false)
+		waitReady(t, conn)
+		conn.blocked.set(amqp.Blocking{Active: true, Reason: "low on memory"})
+
+		published := make(chan error, 1)
+		go func() { published <- pool.Publish("exchange", "key", false, false, amqp.Publishing{}) }()
+		assert.Eventually(t, func() bool { return pool.channels[0].pendingCount() == 1 }, time.Second, time.Millisecond)
+		assert.NoError(t, pool.Close())
+		assert.ErrorIs(t, <-published, ErrNotConfirmed)
+	})
+
+	t.Run("channels are rebuilt after reconnects", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		pool := conn.ChannelPool(2)
+		assert.Eventually(t, func() bool { return pool.PublishJSON("exchange", "key", false, false, "ready") == nil }, time.Second, time.Millisecond)
+
+		broker.dropConnections()
+
+		assert.Eventually(t, func() bool {
+			return conn.State() == StateTopologyApplied && pool.PublishJSON("exchange", "key", false, false, "again") == nil
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,151 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClosed is returned by WaitReady once the Connection or Channel was
+// closed.
+var ErrClosed = errors.New("chamqp: closed")
+
+// readiness is a signal that is closed while ready and re-armed once it is
+// not. Its zero value is not ready.
+type readiness struct {
+	mu       sync.Mutex
+	ready    chan struct{}
+	isReady  bool
+	lastErr  error
+	done     chan struct{}
+	finalErr error
+}
+
+func (r *readiness) init() {
+	if r.ready == nil {
+		r.ready = make(chan struct{})
+		r.done = make(chan struct{})
+	}
+}
+
+func (r *readiness) signal() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.init()
+	return r.ready
+}
+
+func (r *readiness) set(ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.init()
+	if ready == r.isReady || r.finalErr != nil {
+		return
+	}
+	r.isReady = ready
+	if ready {
+		close(r.ready)
+	} else {
+		r.ready = make(chan struct{})
+	}
+}
+
+// fail records err as the reason for not being ready.
+func (r *readiness) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastErr = err
+}
+
+// close makes waiters return err, since it never becomes ready again.
+func (r *readiness) close(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.init()
+	if r.finalErr != nil {
+		return
+	}
+	if r.isReady {
+		r.isReady = false
+		r.ready = make(chan struct{})
+	}
+	r.finalErr = err
+	close(r.done)
+}
+
+func (r *readiness) state() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finalErr != nil {
+		return false, r.finalErr
+	}
+	return r.isReady, r.lastErr
+}
+
+// closed reports whether close was called.
+func (r *readiness) closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.finalErr != nil
+}
+
+func (r *readiness) wait(ctx context.Context) error {
+	r.mu.Lock()
+	r.init()
+	ready, done := r.ready, r.done
+	r.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-done:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.finalErr
+	case <-ctx.Done():
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.lastErr == nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w (last error: %w)", ctx.Err(), r.lastErr)
+	}
+}
+
+// Ready returns a channel that is closed once the connection is established
+// and all channels applied their topology. After a disconnect it returns a
+// new channel for the next time the connection becomes ready.
+func (c *Connection) Ready() <-chan struct{} {
+	return c.ready.signal()
+}
+
+// WaitReady blocks until the connection is established and all channels
+// applied their topology. Once ctx is done it returns ctx.Err() together
+// with the last connection error. After Close it returns ErrClosed, and the
+// error of the ReconnectPolicy once it gave up.
+func (c *Connection) WaitReady(ctx context.Context) error {
+	return c.ready.wait(ctx)
+}
+
+// Ready returns a channel that is closed once the channel is open and all
+// its declarations, bindings and consumers are applied. After a disconnect it
+// returns a new channel for the next time the channel becomes ready.
+func (ch *Channel) Ready() <-chan struct{} {
+	return ch.ready.signal()
+}
+
+// WaitReady blocks until the channel is open and all its declarations,
+// bindings and consumers are applied. Once ctx is done it returns ctx.Err()
+// together with the last error of the channel. After Close of the channel or
+// its Connection it returns ErrClosed, and ErrRestoreGaveUp once restoring the
+// channel gave up.
+func (ch *Channel) WaitReady(ctx context.Context) error {
+	return ch.ready.wait(ctx)
+}
@@ -0,0 +1,140 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func isClosed(signal <-chan struct{}) bool {
+	select {
+	case <-signal:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("re-arms after becoming unready", func(t *testing.T) {
+		var r readiness
+		first := r.signal()
+		assert.False(t, isClosed(first))
+
+		r.set(true)
+		assert.True(t, isClosed(first))
+		assert.NoError(t, r.wait(context.Background()))
+
+		r.set(false)
+		second := r.signal()
+		assert.False(t, isClosed(second))
+		r.set(true)
+		assert.True(t, isClosed(second))
+	})
+
+	t.Run("timeout reports the last error", func(t *testing.T) {
+		var r readiness
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, r.wait(ctx), context.DeadlineExceeded)
+
+		r.fail(errors.New("refused"))
+		err := r.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "refused")
+	})
+
+	t.Run("closing releases waiters", func(t *testing.T) {
+		var r readiness
+		r.set(true)
+		r.close(ErrClosed)
+		r.set(true)
+		assert.False(t, isClosed(r.signal()))
+		assert.ErrorIs(t, r.wait(context.Background()), ErrClosed)
+	})
+}
+
+func TestWaitReady(t *testing.T) {
+	t.Run("ready once the topology is applied", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		dials := make(chan struct{}, 2)
+		dials <- struct{}{}
+		conn := DialConfig(broker.url(), amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				<-dials
+				return net.Dial(network, addr)
+			},
+		}, WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		ch := conn.Channel()
+		ch.QueueDeclare("orders", true, false, false, false, nil, nil, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, conn.WaitReady(ctx))
+		assert.NoError(t, ch.WaitReady(ctx))
+		assert.NoError(t, ch.PublishJSON("exchange", "key", false, false, "payload"))
+
+		broker.dropConnections()
+		assert.Eventually(t, func() bool { return !isClosed(conn.Ready()) && !isClosed(ch.Ready()) }, time.Second, time.Millisecond)
+
+		dials <- struct{}{}
+		assert.NoError(t, conn.WaitReady(ctx))
+		assert.NoError(t, ch.WaitReady(ctx))
+	})
+
+	t.Run("timeout returns the last connection error", func(t *testing.T) {
+		conn := DialConfig(unreachableURL, amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return nil, errors.New("refused")
+			},
+		}, WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		ch := conn.Channel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := conn.WaitReady(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "refused")
+		assert.ErrorContains(t, ch.WaitReady(ctx), "refused")
+
+		assert.NoError(t, conn.Close())
+		assert.ErrorIs(t, conn.WaitReady(context.Background()), ErrClosed)
+		assert.ErrorIs(t, ch.WaitReady(context.Background()), ErrClosed)
+	})
+
+	t.Run("a failed replay resets channels replayed before", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		release := make(chan struct{})
+		conn := DialConfig(broker.url(), amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				<-release
+				return net.Dial(network, addr)
+			},
+		}, WithReconnectPolicy(ConstantBackoff{Interval: time.Hour}))
+		defer conn.Close()
+		good := conn.Channel()
+		good.QueueDeclare("orders", true, false, false, false, nil, nil, nil)
+		bad := conn.Channel()
+		bad.QueueDeclare("missing", true, false, false, false, nil, nil, nil)
+		close(release)
+
+		assert.Eventually(t, func() bool { return conn.State() == StateReconnecting }, time.Second, time.Millisecond)
+		assert.False(t, isClosed(good.Ready()))
+		assert.Nil(t, good.ch.Load())
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, good.WaitReady(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("a closed channel is never ready", func(t *testing.T) {
+		ch := newConnection(nil).Channel()
+		assert.NoError(t, ch.Close())
+		assert.ErrorIs(t, ch.WaitReady(context.Background()), ErrClosed)
+	})
+}
@@ -0,0 +1,84 @@
+package chamqp
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ErrReconnectGaveUp is returned by Connection.Err once the ReconnectPolicy
+// stopped further connection attempts.
+var ErrReconnectGaveUp = errors.New("chamqp: reconnect policy gave up")
+
+// ErrRestoreGaveUp is returned by Channel.WaitReady once the ReconnectPolicy
+// stopped restoring a channel closed by the server. The channel stays closed,
+// also after a reconnect.
+var ErrRestoreGaveUp = errors.New("chamqp: channel restore gave up")
+
+// ReconnectPolicy decides how long the supervisor waits before the next
+// connection attempt and when it stops trying altogether.
+type ReconnectPolicy interface {
+	// NextBackoff is called after a failed connection attempt with the
+	// number of consecutive failures so far (starting at 1). It returns the
+	// delay before the next attempt, or false to give up.
+	NextBackoff(failures int) (time.Duration, bool)
+}
+
+// ExponentialBackoff is a ReconnectPolicy whose delay grows by Multiplier
+// with every consecutive failure, bounded by MaxInterval.
+type ExponentialBackoff struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration // 0 means unbounded
+	Multiplier      float64       // values below 1 mean 2
+	Jitter          float64       // randomization factor between 0 and 1
+	MaxAttempts     int           // 0 means retry forever
+}
+
+// DefaultReconnectPolicy is used when no policy is passed at dial time. It
+// starts at one second, doubles up to ten seconds and gives up after ten
+// consecutive failures.
+var DefaultReconnectPolicy ReconnectPolicy = ExponentialBackoff{
+	InitialInterval: initialInterval,
+	MaxInterval:     maxInterval,
+	Multiplier:      multiplier,
+	MaxAttempts:     maxAttemps,
+}
+
+// NextBackoff implements ReconnectPolicy.
+func (b ExponentialBackoff) NextBackoff(failures int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && failures >= b.MaxAttempts {
+		return 0, false
+	}
+
+	factor := b.Multiplier
+	if factor < 1 {
+		factor = multiplier
+	}
+	delay := float64(b.InitialInterval) * math.Pow(factor, float64(failures-1))
+	if b.MaxInterval > 0 && delay > float64(b.MaxInterval) {
+		delay = float64(b.MaxInterval)
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	// Jitter must not push the delay beyond MaxInterval either.
+	if b.MaxInterval > 0 && delay > float64(b.MaxInterval) {
+		delay = float64(b.MaxInterval)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay), true
+}
+
+// ConstantBackoff is a ReconnectPolicy that always waits Interval and never
+// gives up.
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+// NextBackoff implements ReconnectPolicy.
+func (b ConstantBackoff) NextBackoff(failures int) (time.Duration, bool) {
+	return b.Interval, true
+}
@@ -0,0 +1,57 @@
+package chamqp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Run("default policy keeps the previous schedule", func(t *testing.T) {
+		expected := []time.Duration{1, 2, 4, 8, 10, 10, 10, 10, 10}
+		for i, want := range expected {
+			delay, ok := DefaultReconnectPolicy.NextBackoff(i + 1)
+			assert.True(t, ok)
+			assert.Equal(t, want*time.Second, delay)
+		}
+		_, ok := DefaultReconnectPolicy.NextBackoff(10)
+		assert.False(t, ok)
+	})
+
+	t.Run("unlimited attempts", func(t *testing.T) {
+		policy := ExponentialBackoff{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}
+		delay, ok := policy.NextBackoff(1000)
+		assert.True(t, ok)
+		assert.Equal(t, time.Minute, delay)
+	})
+
+	t.Run("zero multiplier doubles", func(t *testing.T) {
+		policy := ExponentialBackoff{InitialInterval: time.Second}
+		for i, want := range []time.Duration{1, 2, 4, 8} {
+			delay, ok := policy.NextBackoff(i + 1)
+			assert.True(t, ok)
+			assert.Equal(t, want*time.Second, delay)
+		}
+	})
+
+	t.Run("jitter stays within bounds", func(t *testing.T) {
+		policy := ExponentialBackoff{InitialInterval: time.Second, Multiplier: 1, Jitter: 0.5}
+		for i := 0; i < 100; i++ {
+			delay, ok := policy.NextBackoff(1)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+		}
+	})
+
+	t.Run("jitter stays below the max interval", func(t *testing.T) {
+		policy := ExponentialBackoff{InitialInterval: time.Second, MaxInterval: time.Second, Jitter: 0.5}
+		for i := 0; i < 100; i++ {
+			delay, ok := policy.NextBackoff(5)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			assert.LessOrEqual(t, delay, time.Second)
+		}
+	})
+}
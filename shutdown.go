@@ -0,0 +1,287 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrShuttingDown is returned by publishes once Shutdown was called.
+var ErrShuttingDown = errors.New("chamqp: shutting down")
+
+// ShutdownError reports what Shutdown abandoned because ctx was done before
+// draining completed.
+type ShutdownError struct {
+	Err                  error // ctx.Err()
+	UnackedDeliveries    int   // handed to consumers but not acknowledged
+	UnconfirmedPublishes int   // published but not confirmed by the broker
+	BufferedPublishes    int   // held back while the connection was blocked
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("chamqp: shutdown abandoned %d unacknowledged deliveries, %d unconfirmed and %d buffered publishes: %v",
+		e.UnackedDeliveries, e.UnconfirmedPublishes, e.BufferedPublishes, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown drains the connection before closing it. It stops reconnecting,
+// cancels all consumers, rejects further publishes with ErrShuttingDown, waits
+// for consumers to acknowledge the deliveries they received and for the
+// broker to confirm publishes, then closes all channels and the connection.
+// If ctx is done first, the connection is closed anyway: while draining a
+// *ShutdownError reports what was abandoned, before that, for example while
+// the supervisor is still dialing, ctx.Err() is returned.
+func (c *Connection) Shutdown(ctx context.Context) error {
+	c.stopSupervision()
+
+	select {
+	case <-c.doneChan:
+	case <-ctx.Done():
+		go c.closeWhenStopped()
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	c.setState(StateClosed, 0, nil)
+	channels := append([]*Channel(nil), c.channels...)
+	c.mu.Unlock()
+
+	for _, ch := range channels {
+		ch.cancelConsumers()
+	}
+	c.draining.Store(true)
+
+	_, unblocked := c.blocked.state()
+	abandoned := &ShutdownError{}
+	for _, ch := range channels {
+		unacked, unconfirmed, buffered := ch.drain(ctx, unblocked)
+		abandoned.UnackedDeliveries += unacked
+		abandoned.UnconfirmedPublishes += unconfirmed
+		abandoned.BufferedPublishes += buffered
+	}
+
+	for _, ch := range channels {
+		err := ch.Close()
+		if err != nil {
+			c.opts.logger.Warn("closing channel failed", "error", err)
+		}
+	}
+	err := c.closeConn(ctx)
+
+	if abandoned.UnackedDeliveries+abandoned.UnconfirmedPublishes+abandoned.BufferedPublishes > 0 {
+		abandoned.Err = ctx.Err()
+		c.opts.logger.Warn("shutdown abandoned messages", "unacked_deliveries", abandoned.UnackedDeliveries,
+			"unconfirmed_publishes", abandoned.UnconfirmedPublishes, "buffered_publishes", abandoned.BufferedPublishes)
+		return abandoned
+	}
+	return err
+}
+
+// cancelConsumers stops deliveries to all consumers of the channel.
+func (ch *Channel) cancelConsumers() {
+	channel := ch.ch.Load()
+	if channel == nil {
+		return
+	}
+
+	ch.specMu.Lock()
+	consumeSpecs := ch.consumeSpecs
+	ch.specMu.Unlock()
+	for _, spec := range consumeSpecs {
+		err := channel.Cancel(spec.Consumer, false)
+		if err != nil {
+			ch.Logger().Warn("cancelling consumer failed", "queue", spec.Queue, "consumer", spec.Consumer, "error", err)
+		}
+	}
+}
+
+// drain waits for buffered publishes to be sent, for deliveries of cancelled
+// consumers to be acknowledged and for publishes to be confirmed. It returns what is still
+// outstanding once ctx is done.
+func (ch *Channel) drain(ctx context.Context, unblocked <-chan struct{}) (unacked, unconfirmed, buffered int) {
+	if ch.pendingCount() > 0 {
+		select {
+		case <-unblocked:
+			ch.flushPending()
+		case <-ctx.Done():
+		}
+	}
+
+	select {
+	case <-ch.deliveries.idle():
+	case <-ctx.Done():
+	}
+
+	ch.publishMu.Lock()
+	confirmations := ch.unconfirmed
+	ch.publishMu.Unlock()
+	for _, confirmation := range confirmations {
+		if isSettled(confirmation) {
+			continue
+		}
+		select {
+		case <-confirmation.Done():
+		case <-ctx.Done():
+			unconfirmed++
+		}
+	}
+
+	return ch.deliveries.count(), unconfirmed, ch.pendingCount()
+}
+
+func (ch *Channel) pendingCount() int {
+	ch.pendingMu.Lock()
+	defer ch.pendingMu.Unlock()
+
+	return len(ch.pending)
+}
+
+// trackConfirmation remembers confirmation until it is settled. It requires
+// publishMu to be held.
+func (ch *Channel) trackConfirmation(confirmation *amqp.DeferredConfirmation) {
+	for len(ch.unconfirmed) > 0 && isSettled(ch.unconfirmed[0]) {
+		ch.unconfirmed = ch.unconfirmed[1:]
+	}
+	ch.unconfirmed = append(ch.unconfirmed, confirmation)
+}
+
+func isSettled(confirmation *amqp.DeferredConfirmation) bool {
+	select {
+	case <-confirmation.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// deliveryTracker counts deliveries handed to consumers until they are
+// acknowledged, and the shovels still forwarding deliveries. Its zero value
+// is ready to use.
+type deliveryTracker struct {
+	mu          sync.Mutex
+	outstanding map[deliveryKey]struct{}
+	shovels     int
+	idleChan    chan struct{}
+}
+
+type deliveryKey struct {
+	acknowledger amqp.Acknowledger
+	tag          uint64
+}
+
+// track makes d report its acknowledgement to the tracker.
+func (t *deliveryTracker) track(d *amqp.Delivery) {
+	if t == nil || d.Acknowledger == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.outstanding == nil {
+		t.outstanding = map[deliveryKey]struct{}{}
+	}
+	t.markBusy()
+	t.outstanding[deliveryKey{d.Acknowledger, d.DeliveryTag}] = struct{}{}
+	d.Acknowledger = &trackedAcknowledger{t, d.Acknowledger}
+}
+
+func (t *deliveryTracker) settle(acknowledger amqp.Acknowledger, tag uint64, multiple bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.outstanding {
+		if key.acknowledger == acknowledger && (key.tag == tag || multiple && key.tag < tag) {
+			delete(t.outstanding, key)
+		}
+	}
+	t.notifyIdle()
+}
+
+// reset forgets all deliveries, since the broker redelivers them once their
+// channel is gone.
+func (t *deliveryTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.outstanding = nil
+	t.notifyIdle()
+}
+
+// startShovel must be called before starting a shovel, stopShovel once it
+// returned.
+func (t *deliveryTracker) startShovel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.markBusy()
+	t.shovels++
+}
+
+func (t *deliveryTracker) stopShovel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.shovels--
+	t.notifyIdle()
+}
+
+func (t *deliveryTracker) markBusy() {
+	if t.idleChan == nil {
+		t.idleChan = make(chan struct{})
+	}
+}
+
+func (t *deliveryTracker) notifyIdle() {
+	if len(t.outstanding) == 0 && t.shovels == 0 && t.idleChan != nil {
+		close(t.idleChan)
+		t.idleChan = nil
+	}
+}
+
+func (t *deliveryTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.outstanding)
+}
+
+// idle returns a channel that is closed once all deliveries are settled and
+// all shovels returned.
+func (t *deliveryTracker) idle() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.idleChan == nil {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return t.idleChan
+}
+
+type trackedAcknowledger struct {
+	tracker *deliveryTracker
+	amqp.Acknowledger
+}
+
+func (a *trackedAcknowledger) Ack(tag uint64, multiple bool) error {
+	defer a.tracker.settle(a.Acknowledger, tag, multiple)
+	return a.Acknowledger.Ack(tag, multiple)
+}
+
+func (a *trackedAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	defer a.tracker.settle(a.Acknowledger, tag, multiple)
+	return a.Acknowledger.Nack(tag, multiple, requeue)
+}
+
+func (a *trackedAcknowledger) Reject(tag uint64, requeue bool) error {
+	defer a.tracker.settle(a.Acknowledger, tag, false)
+	return a.Acknowledger.Reject(tag, requeue)
+}
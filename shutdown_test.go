@@ -0,0 +1,163 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func readyConnection(t *testing.T, broker *fakeBroker) *Connection {
+	conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitReady(t *testing.T, conn *Connection) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, conn.WaitReady(ctx))
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("waits for acknowledgements and confirms", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		deliveries := make(chan amqp.Delivery, 10)
+		conn.Channel().Consume("orders", "", false, false, false, false, nil, deliveries, nil)
+		publisher := conn.ChannelWithConfirm(false)
+		waitReady(t, conn)
+
+		var handled atomic.Int64
+		go func() {
+			for delivery := range deliveries {
+				time.Sleep(5 * time.Millisecond)
+				delivery.Ack(false)
+				handled.Add(1)
+			}
+		}()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, 1, broker.deliver("orders", []byte("order")))
+			assert.NoError(t, publisher.PublishJSON("exchange", "key", false, false, i))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, conn.Shutdown(ctx))
+		assert.Equal(t, int64(3), handled.Load())
+		assert.Equal(t, int64(3), broker.acks.Load())
+		assert.Equal(t, StateClosed, conn.State())
+		assert.ErrorIs(t, publisher.PublishJSON("exchange", "key", false, false, "late"), ErrShuttingDown)
+	})
+
+	t.Run("reports what was abandoned", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		broker.holdConfirms.Store(true)
+		conn := readyConnection(t, broker)
+		deliveries := make(chan amqp.Delivery, 10)
+		conn.Channel().Consume("orders", "", false, false, false, false, nil, deliveries, nil)
+		publisher := conn.ChannelWithConfirm(false)
+		waitReady(t, conn)
+
+		broker.deliver("orders", []byte("order"))
+		broker.deliver("orders", []byte("order"))
+		assert.NoError(t, publisher.PublishJSON("exchange", "key", false, false, "unconfirmed"))
+		<-deliveries
+		<-deliveries
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := conn.Shutdown(ctx)
+
+		var abandoned *ShutdownError
+		assert.True(t, errors.As(err, &abandoned))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 2, abandoned.UnackedDeliveries)
+		assert.Equal(t, 1, abandoned.UnconfirmedPublishes)
+		assert.Zero(t, abandoned.BufferedPublishes)
+		assert.Equal(t, StateClosed, conn.State())
+	})
+
+	t.Run("settled confirmations are not abandoned", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		publisher := conn.ChannelWithConfirm(false)
+		waitReady(t, conn)
+
+		assert.NoError(t, publisher.PublishJSON("exchange", "key", false, false, "confirmed"))
+		publisher.publishMu.Lock()
+		confirmations := publisher.unconfirmed
+		publisher.publishMu.Unlock()
+		assert.NotEmpty(t, confirmations)
+		for _, confirmation := range confirmations {
+			<-confirmation.Done()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 20; i++ {
+			_, unconfirmed, _ := publisher.drain(ctx, nil)
+			assert.Zero(t, unconfirmed)
+		}
+	})
+
+	t.Run("closes the connection after a timeout while dialing", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		dialing := make(chan struct{})
+		release := make(chan struct{})
+		conn := DialConfig(broker.url(), amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				close(dialing)
+				<-release
+				return net.Dial(network, addr)
+			},
+		})
+		<-dialing
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, conn.Shutdown(ctx), context.DeadlineExceeded)
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			return broker.closes.Load() == 1 && conn.State() == StateClosed
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("auto-acked deliveries are not tracked", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		deliveries := make(chan amqp.Delivery, 10)
+		conn.Channel().Consume("orders", "", true, false, false, false, nil, deliveries, nil)
+		waitReady(t, conn)
+
+		broker.deliver("orders", []byte("order"))
+		<-deliveries
+		assert.NoError(t, conn.Shutdown(context.Background()))
+	})
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	t.Run("multiple acknowledges earlier tags of the same channel", func(t *testing.T) {
+		var tracker deliveryTracker
+		first, second := &amqp.Channel{}, &amqp.Channel{}
+		for tag := uint64(1); tag <= 3; tag++ {
+			tracker.track(&amqp.Delivery{Acknowledger: first, DeliveryTag: tag})
+		}
+		tracker.track(&amqp.Delivery{Acknowledger: second, DeliveryTag: 1})
+
+		tracker.settle(first, 2, true)
+		assert.Equal(t, 2, tracker.count())
+		tracker.settle(first, 3, false)
+		assert.Equal(t, 1, tracker.count())
+		assert.False(t, isClosed(tracker.idle()))
+
+		tracker.reset()
+		assert.True(t, isClosed(tracker.idle()))
+	})
+}
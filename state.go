@@ -0,0 +1,134 @@
+package chamqp
+
+// State describes where a Connection is in its lifecycle.
+type State int
+
+const (
+	// StateConnecting is the initial state until the first connection
+	// attempt succeeds.
+	StateConnecting State = iota
+	// StateConnected means the AMQP connection is open but the registered
+	// channels have not been restored yet.
+	StateConnected
+	// StateTopologyApplied means all channels were (re)opened and their
+	// declarations, bindings and consumers applied.
+	StateTopologyApplied
+	// StateDisconnected is entered when the server or the network closed an
+	// established connection.
+	StateDisconnected
+	// StateReconnecting means the supervisor waits for the next connection
+	// attempt.
+	StateReconnecting
+	// StateClosed is final, either after Close or once the ReconnectPolicy
+	// gave up.
+	StateClosed
+)
+
+var stateNames = map[State]string{
+	StateConnecting:      "connecting",
+	StateConnected:       "connected",
+	StateTopologyApplied: "topology-applied",
+	StateDisconnected:    "disconnected",
+	StateReconnecting:    "reconnecting",
+	StateClosed:          "closed",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// StateChange is sent to NotifyState receivers on every transition.
+type StateChange struct {
+	State State
+	// Attempt is the number of consecutive failed connection attempts.
+	Attempt int
+	// Err is the error that caused the transition, if any.
+	Err error
+	// Endpoint is the broker URI in use, with the password redacted.
+	Endpoint string
+}
+
+// State returns the current state of the connection.
+func (c *Connection) State() State {
+	return c.currentState().State
+}
+
+// Endpoint returns the broker URI the connection uses or last tried, with
+// the password redacted.
+func (c *Connection) Endpoint() string {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.endpoint
+}
+
+func (c *Connection) setEndpoint(endpoint string) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.endpoint = endpoint
+}
+
+func (c *Connection) currentState() StateChange {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.state
+}
+
+// NotifyState registers a listener for state transitions. Sends never block
+// the supervisor, so transitions are dropped while the receiver is not ready;
+// use a buffered channel.
+func (c *Connection) NotifyState(receiver chan StateChange) chan StateChange {
+	c.stateSubs.add(receiver, DropNewest)
+
+	return receiver
+}
+
+// SubscribeState registers a listener for state transitions like
+// NotifyState, with the given overflow policy and a way to unsubscribe.
+func (c *Connection) SubscribeState(receiver chan StateChange, overflow OverflowPolicy) *Subscription {
+	return c.stateSubs.add(receiver, overflow)
+}
+
+// setState must be called with c.mu held.
+func (c *Connection) setState(state State, attempt int, err error) {
+	c.statusMu.Lock()
+	if c.state.State == StateClosed {
+		c.statusMu.Unlock()
+		return
+	}
+	change := StateChange{state, attempt, err, c.endpoint}
+	c.state = change
+	final := c.err
+	c.statusMu.Unlock()
+	c.stateSubs.send(change)
+
+	if err != nil {
+		// Channels cannot become ready without the connection either.
+		c.ready.fail(err)
+		for _, ch := range c.channels {
+			ch.ready.fail(err)
+		}
+	}
+	c.ready.set(state == StateTopologyApplied)
+	if state == StateClosed {
+		if final == nil {
+			final = ErrClosed
+		}
+		c.ready.close(final)
+		for _, ch := range c.channels {
+			ch.ready.close(final)
+		}
+	}
+}
+
+func (c *Connection) updateState(state State, attempt int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setState(state, attempt, err)
+}
@@ -0,0 +1,120 @@
+package chamqp
+
+import (
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Status is a snapshot of a Connection for health checks.
+type Status struct {
+	State    State
+	Endpoint string
+	// ConnectedSince is when the current connection was established, zero
+	// while disconnected.
+	ConnectedSince time.Time
+	// LastConnected is when the last connection was established. Unlike
+	// ConnectedSince it is kept while disconnected.
+	LastConnected time.Time
+	// Reconnects counts connections established after the first one.
+	Reconnects int
+	// TopologyErr is why replaying the topology failed on the last
+	// connection attempt, nil once it succeeded.
+	TopologyErr error
+	Channels    []ChannelStatus
+	// Consumers counts the active consumers of all channels per queue.
+	Consumers map[string]int
+}
+
+// ChannelStatus is a snapshot of a Channel for health checks.
+type ChannelStatus struct {
+	// Ready is true while the channel is open with its topology applied.
+	Ready   bool
+	Confirm bool
+	// Consumers counts the active consumers per queue.
+	Consumers map[string]int
+	// Err is the last error of the channel while it is not ready.
+	Err error
+}
+
+// Status reports the state of the connection and its channels.
+func (c *Connection) Status() Status {
+	// Not c.mu, which is held while the topology is replayed.
+	c.statusMu.Lock()
+	status := Status{
+		State:          c.state.State,
+		Endpoint:       c.endpoint,
+		ConnectedSince: c.connectedAt,
+		LastConnected:  c.lastConnectedAt,
+		TopologyErr:    c.topologyErr,
+		Consumers:      map[string]int{},
+	}
+	if c.connections > 0 {
+		status.Reconnects = c.connections - 1
+	}
+	channels := append([]*Channel(nil), c.channels...)
+	c.statusMu.Unlock()
+
+	for _, ch := range channels {
+		chStatus := ch.Status()
+		for queue, n := range chStatus.Consumers {
+			status.Consumers[queue] += n
+		}
+		status.Channels = append(status.Channels, chStatus)
+	}
+	return status
+}
+
+// Status reports whether the channel is ready and its active consumers.
+func (ch *Channel) Status() ChannelStatus {
+	ready, err := ch.ready.state()
+	status := ChannelStatus{Ready: ready, Confirm: ch.confirm, Consumers: ch.consumers.count()}
+	if !ready {
+		status.Err = err
+	}
+	return status
+}
+
+// consumerKey identifies a consumer on one AMQP channel, so a consumer
+// ending on a closed channel does not remove its restored successor.
+type consumerKey struct {
+	channel *amqp.Channel
+	tag     string
+}
+
+// activeConsumers tracks the consumers whose deliveries are shovelled,
+// with the queue each consumes from.
+type activeConsumers struct {
+	mu     sync.Mutex
+	queues map[consumerKey]string
+}
+
+func (a *activeConsumers) add(key consumerKey, queue string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.queues == nil {
+		a.queues = map[consumerKey]string{}
+	}
+	a.queues[key] = queue
+}
+
+func (a *activeConsumers) remove(key consumerKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.queues, key)
+}
+
+// count returns the number of active consumers per queue.
+func (a *activeConsumers) count() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, queue := range a.queues {
+		counts[queue]++
+	}
+	return counts
+}
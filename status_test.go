@@ -0,0 +1,107 @@
+package chamqp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	t.Run("active consumers and reconnects", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		ch := conn.ChannelWithConfirm(false)
+		ch.Consume("orders", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		ch.Consume("orders", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		conn.Channel().Consume("invoices", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, conn.WaitReady(ctx))
+
+		status := conn.Status()
+		assert.Equal(t, StateTopologyApplied, status.State)
+		assert.NoError(t, status.TopologyErr)
+		assert.Zero(t, status.Reconnects)
+		assert.WithinDuration(t, time.Now(), status.ConnectedSince, time.Second)
+		assert.Equal(t, map[string]int{"orders": 2, "invoices": 1}, status.Consumers)
+		assert.Equal(t, ChannelStatus{Ready: true, Confirm: true, Consumers: map[string]int{"orders": 2}}, status.Channels[0])
+
+		broker.dropConnections()
+		assert.Eventually(t, func() bool { return conn.Status().Reconnects == 1 && conn.Status().State == StateTopologyApplied }, time.Second, time.Millisecond)
+		assert.Eventually(t, func() bool { return conn.Status().Consumers["orders"] == 2 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("consumers end with their channel", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.Consume("orders", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		waitReady(t, conn)
+		assert.Equal(t, map[string]int{"orders": 1}, ch.Status().Consumers)
+
+		assert.NoError(t, ch.Close())
+		assert.Eventually(t, func() bool { return len(ch.Status().Consumers) == 0 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("last connect is kept while disconnected", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Hour}))
+		defer conn.Close()
+		waitReady(t, conn)
+		connected := conn.Status().ConnectedSince
+
+		broker.listener.Close()
+		broker.dropConnections()
+		assert.Eventually(t, func() bool { return conn.State() == StateReconnecting }, time.Second, time.Millisecond)
+		status := conn.Status()
+		assert.Zero(t, status.ConnectedSince)
+		assert.Equal(t, connected, status.LastConnected)
+	})
+
+	t.Run("failed topology replay", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := Dial(broker.url(), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		conn.Channel().QueueDeclare("missing", true, false, false, false, nil, nil, nil)
+
+		assert.Eventually(t, func() bool { return conn.Status().TopologyErr != nil }, time.Second, time.Millisecond)
+		status := conn.Status()
+		assert.ErrorContains(t, status.TopologyErr, "NOT_FOUND")
+		assert.False(t, status.Channels[0].Ready)
+		assert.Empty(t, status.Consumers)
+	})
+
+	t.Run("readable while dialing", func(t *testing.T) {
+		release := make(chan struct{})
+		dialing := make(chan struct{})
+		conn := DialConfig("amqp://127.0.0.1:1/", amqp.Config{
+			Dial: func(network, addr string) (net.Conn, error) {
+				close(dialing)
+				<-release
+				return nil, errors.New("refused")
+			},
+		}, WithReconnectPolicy(ExponentialBackoff{MaxAttempts: 1}))
+		conn.Channel()
+		<-dialing
+
+		read := make(chan Status)
+		go func() { read <- conn.Status() }()
+		select {
+		case status := <-read:
+			assert.Equal(t, StateConnecting, status.State)
+			assert.False(t, status.Channels[0].Ready)
+		case <-time.After(time.Second):
+			t.Error("status blocked on the dial")
+		}
+
+		close(release)
+		assert.NoError(t, conn.Close())
+	})
+}
@@ -0,0 +1,99 @@
+package chamqp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides which event is lost when a notification receiver is
+// full. Notifications never block the connection.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the event that does not fit into the receiver.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest
+)
+
+// Subscription is returned when registering a notification receiver.
+type Subscription struct {
+	unsubscribe func()
+	dropped     *atomic.Uint64
+}
+
+// Unsubscribe stops notifications to the receiver. The receiver is not
+// closed. It is safe to call Unsubscribe more than once.
+func (s *Subscription) Unsubscribe() {
+	s.unsubscribe()
+}
+
+// Dropped returns how many events did not fit into the receiver.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+type subscriber[T any] struct {
+	receiver chan T
+	overflow OverflowPolicy
+	dropped  atomic.Uint64
+}
+
+// subscribers fans events out to receivers without ever blocking the sender.
+// It has its own lock so events can be sent while c.mu is held.
+type subscribers[T any] struct {
+	mu   sync.Mutex
+	list []*subscriber[T]
+}
+
+func (s *subscribers[T]) add(receiver chan T, overflow OverflowPolicy) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := &subscriber[T]{receiver: receiver, overflow: overflow}
+	s.list = append(s.list, sub)
+
+	return &Subscription{
+		unsubscribe: func() { s.remove(sub) },
+		dropped:     &sub.dropped,
+	}
+}
+
+func (s *subscribers[T]) remove(sub *subscriber[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, candidate := range s.list {
+		if candidate == sub {
+			s.list = append(s.list[:i:i], s.list[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *subscribers[T]) send(event T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.list {
+		select {
+		case sub.receiver <- event:
+			continue
+		default:
+		}
+
+		if sub.overflow == DropOldest {
+			select {
+			case <-sub.receiver:
+				sub.dropped.Add(1)
+			default:
+			}
+			select {
+			case sub.receiver <- event:
+				continue
+			default:
+			}
+		}
+		sub.dropped.Add(1)
+	}
+}
@@ -0,0 +1,73 @@
+package chamqp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribers(t *testing.T) {
+	t.Run("drop newest keeps buffered events", func(t *testing.T) {
+		var subs subscribers[int]
+		receiver := make(chan int, 1)
+		sub := subs.add(receiver, DropNewest)
+
+		subs.send(1)
+		subs.send(2)
+
+		assert.Equal(t, 1, <-receiver)
+		assert.Equal(t, uint64(1), sub.Dropped())
+	})
+
+	t.Run("drop oldest keeps the latest event", func(t *testing.T) {
+		var subs subscribers[int]
+		receiver := make(chan int, 1)
+		sub := subs.add(receiver, DropOldest)
+
+		subs.send(1)
+		subs.send(2)
+
+		assert.Equal(t, 2, <-receiver)
+		assert.Equal(t, uint64(1), sub.Dropped())
+	})
+
+	t.Run("abandoned receivers never block", func(t *testing.T) {
+		var subs subscribers[int]
+		sub := subs.add(make(chan int), DropOldest)
+
+		subs.send(1)
+
+		assert.Equal(t, uint64(1), sub.Dropped())
+	})
+
+	t.Run("unsubscribe stops delivery", func(t *testing.T) {
+		var subs subscribers[int]
+		receiver := make(chan int, 1)
+		other := make(chan int, 1)
+		sub := subs.add(receiver, DropNewest)
+		subs.add(other, DropNewest)
+
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+		subs.send(1)
+
+		assert.Len(t, receiver, 0)
+		assert.Equal(t, 1, <-other)
+	})
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	t.Run("a full receiver does not stall the connection", func(t *testing.T) {
+		conn := newConnection(nil)
+		conn.NotifyError(make(chan error))
+		receiver := make(chan error, 1)
+		sub := conn.SubscribeErrors(receiver, DropOldest)
+
+		conn.disconnect(errors.New("first"))
+		conn.disconnect(errors.New("second"))
+
+		assert.EqualError(t, <-receiver, "second")
+		assert.Equal(t, uint64(1), sub.Dropped())
+	})
+}
@@ -0,0 +1,137 @@
+package chamqp
+
+import (
+	"fmt"
+	"reflect"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// The methods below change the topology on the broker and, once the broker
+// confirmed the change, remove the affected specs of all channels of the
+// connection so they are not restored on reconnect; the topology is shared no
+// matter which channel declared it. Without an open channel they fail and
+// leave the specs in place.
+
+// ExchangeUnbind removes a binding made with ExchangeBind. Bindings are
+// matched by destination, key, source and args.
+func (ch *Channel) ExchangeUnbind(destination, key, source string, noWait bool, args amqp.Table) error {
+	return ch.teardown(func(channel *amqp.Channel) error {
+		return channel.ExchangeUnbind(destination, key, source, noWait, args)
+	}, func(other *Channel) {
+		other.exchangeBindSpecs = without(other.exchangeBindSpecs, func(spec ExchangeBindSpec) bool {
+			return spec.Destination == destination && spec.Key == key && spec.Source == source && sameArgs(spec.Args, args)
+		})
+	})
+}
+
+// ExchangeDelete deletes the exchange together with its declaration and all
+// bindings from or to it.
+func (ch *Channel) ExchangeDelete(name string, ifUnused, noWait bool) error {
+	return ch.teardown(func(channel *amqp.Channel) error {
+		return channel.ExchangeDelete(name, ifUnused, noWait)
+	}, func(other *Channel) {
+		other.exchangeDeclareSpecs = without(other.exchangeDeclareSpecs, func(spec ExchangeDeclareSpec) bool {
+			return spec.Name == name
+		})
+		other.exchangeBindSpecs = without(other.exchangeBindSpecs, func(spec ExchangeBindSpec) bool {
+			return spec.Destination == name || spec.Source == name
+		})
+		other.queueBindSpecs = without(other.queueBindSpecs, func(spec QueueBindSpec) bool {
+			return spec.Exchange == name
+		})
+	})
+}
+
+// QueueUnbind removes a binding made with QueueBind. Bindings are matched by
+// name, key, exchange and args.
+func (ch *Channel) QueueUnbind(name, key, exchange string, args amqp.Table) error {
+	return ch.teardown(func(channel *amqp.Channel) error {
+		return channel.QueueUnbind(name, key, exchange, args)
+	}, func(other *Channel) {
+		other.queueBindSpecs = without(other.queueBindSpecs, func(spec QueueBindSpec) bool {
+			return spec.Name == name && spec.Key == key && spec.Exchange == exchange && sameArgs(spec.Args, args)
+		})
+	})
+}
+
+// QueueDelete deletes the queue together with its declaration, its bindings
+// and its consumers, and returns the number of messages it held. Queues
+// declared with an empty name are not matched, since their name is only known
+// to the broker.
+func (ch *Channel) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	var purged int
+	err := ch.teardown(func(channel *amqp.Channel) (err error) {
+		purged, err = channel.QueueDelete(name, ifUnused, ifEmpty, noWait)
+		return err
+	}, func(other *Channel) {
+		other.queueDeclareSpecs = without(other.queueDeclareSpecs, func(spec QueueDeclareSpec) bool {
+			return spec.Name == name
+		})
+		other.queueBindSpecs = without(other.queueBindSpecs, func(spec QueueBindSpec) bool {
+			return spec.Name == name
+		})
+		other.consumeSpecs = without(other.consumeSpecs, func(spec ConsumeSpec) bool {
+			return spec.Queue == name
+		})
+	})
+	return purged, err
+}
+
+// QueuePurge removes all messages from the queue that are not awaiting
+// acknowledgement and returns how many were removed. It leaves the specs
+// untouched.
+func (ch *Channel) QueuePurge(name string, noWait bool) (int, error) {
+	channel := ch.ch.Load()
+	if channel == nil {
+		return 0, fmt.Errorf("context has no channel")
+	}
+	return channel.QueuePurge(name, noWait)
+}
+
+// teardown runs change on the open channel and, once it succeeded, prunes the
+// specs of every channel of the connection.
+func (ch *Channel) teardown(change func(*amqp.Channel) error, prune func(*Channel)) error {
+	channels := []*Channel{ch}
+	if ch.conn != nil {
+		// Also keeps a replay from restoring what is being removed.
+		ch.conn.mu.Lock()
+		defer ch.conn.mu.Unlock()
+		channels = ch.conn.channels
+	}
+
+	channel := ch.ch.Load()
+	if channel == nil {
+		return fmt.Errorf("context has no channel")
+	}
+	err := change(channel)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range channels {
+		other.specMu.Lock()
+		prune(other)
+		other.specMu.Unlock()
+	}
+	return nil
+}
+
+// sameArgs compares binding arguments, treating nil and empty tables alike.
+func sameArgs(a, b amqp.Table) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// without returns specs without the ones matching remove.
+func without[T any](specs []T, remove func(T) bool) []T {
+	var kept []T
+	for _, spec := range specs {
+		if !remove(spec) {
+			kept = append(kept, spec)
+		}
+	}
+	return kept
+}
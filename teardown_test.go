@@ -0,0 +1,102 @@
+package chamqp
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeardown(t *testing.T) {
+	t.Run("removes deleted topology from the replay", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.ExchangeDeclare("central", "topic", true, false, false, false, nil, nil)
+		ch.ExchangeDeclare("orders", "topic", true, false, false, false, nil, nil)
+		ch.ExchangeBind("orders", "orders.#", "central", false, nil, nil)
+		ch.QueueDeclare("invoices", true, false, false, false, nil, nil, nil)
+		ch.QueueDeclare("shipments", true, false, false, false, nil, nil, nil)
+		ch.QueueBind("invoices", "invoices.#", "central", false, nil, nil)
+		ch.QueueBind("shipments", "shipments.#", "central", false, nil, nil)
+		ch.QueueBind("shipments", "shipments.#", "orders", false, nil, nil)
+		ch.Consume("invoices", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		waitReady(t, conn)
+
+		_, err := ch.QueueDelete("invoices", false, false, false)
+		assert.NoError(t, err)
+		assert.NoError(t, ch.ExchangeDelete("orders", false, false))
+		assert.NoError(t, ch.QueueUnbind("shipments", "shipments.#", "central", nil))
+		_, err = ch.QueuePurge("shipments", false)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []ExchangeDeclareSpec{{Name: "central", Kind: "topic", Durable: true}}, ch.exchangeDeclareSpecs)
+		assert.Empty(t, ch.exchangeBindSpecs)
+		assert.Equal(t, []QueueDeclareSpec{{Name: "shipments", Durable: true}}, ch.queueDeclareSpecs)
+		assert.Empty(t, ch.queueBindSpecs)
+		assert.Empty(t, ch.consumeSpecs)
+
+		before := len(broker.methods())
+		broker.dropConnections()
+		assert.Eventually(t, func() bool {
+			return len(broker.methods()) == before+2 && conn.State() == StateTopologyApplied
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, []string{"exchange.declare", "queue.declare"}, broker.methods()[before:])
+	})
+
+	t.Run("prunes the specs of other channels", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		declaring := conn.Channel()
+		declaring.ExchangeDeclare("central", "topic", true, false, false, false, nil, nil)
+		declaring.QueueDeclare("invoices", true, false, false, false, nil, nil, nil)
+		declaring.QueueBind("invoices", "invoices.#", "central", false, nil, nil)
+		consuming := conn.Channel()
+		consuming.Consume("invoices", "", false, false, false, false, nil, make(chan amqp.Delivery), nil)
+		deleting := conn.Channel()
+		waitReady(t, conn)
+
+		_, err := deleting.QueueDelete("invoices", false, false, false)
+		assert.NoError(t, err)
+		assert.NoError(t, deleting.ExchangeDelete("central", false, false))
+		assert.Empty(t, declaring.exchangeDeclareSpecs)
+		assert.Empty(t, declaring.queueDeclareSpecs)
+		assert.Empty(t, declaring.queueBindSpecs)
+		assert.Empty(t, consuming.consumeSpecs)
+
+		before := len(broker.methods())
+		broker.dropConnections()
+		assert.Eventually(t, func() bool { return conn.Status().Reconnects == 1 && conn.State() == StateTopologyApplied }, time.Second, time.Millisecond)
+		assert.Len(t, broker.methods(), before)
+	})
+
+	t.Run("nil and empty args match", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		conn := readyConnection(t, broker)
+		ch := conn.Channel()
+		ch.QueueBind("invoices", "invoices.#", "central", false, nil, nil)
+		ch.ExchangeBind("orders", "orders.#", "central", false, amqp.Table{}, nil)
+		ch.QueueBind("shipments", "shipments.#", "central", false, amqp.Table{"x-match": "all"}, nil)
+		waitReady(t, conn)
+
+		assert.NoError(t, ch.QueueUnbind("invoices", "invoices.#", "central", amqp.Table{}))
+		assert.NoError(t, ch.ExchangeUnbind("orders", "orders.#", "central", false, nil))
+		assert.NoError(t, ch.QueueUnbind("shipments", "shipments.#", "central", nil))
+		assert.Empty(t, ch.exchangeBindSpecs)
+		assert.Len(t, ch.queueBindSpecs, 1)
+		assert.Equal(t, "shipments", ch.queueBindSpecs[0].Name)
+	})
+
+	t.Run("keeps the specs without a channel", func(t *testing.T) {
+		ch := &Channel{}
+		ch.QueueDeclare("invoices", true, false, false, false, nil, nil, nil)
+		ch.QueueBind("invoices", "invoices.#", "central", false, nil, nil)
+
+		_, err := ch.QueueDelete("invoices", false, false, false)
+		assert.Error(t, err)
+		assert.Error(t, ch.QueueUnbind("invoices", "invoices.#", "central", nil))
+		assert.Len(t, ch.queueDeclareSpecs, 1)
+		assert.Len(t, ch.queueBindSpecs, 1)
+	})
+}
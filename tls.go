@@ -0,0 +1,183 @@
+package chamqp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSFiles locates PEM encoded TLS material, for example certificates
+// rotated by cert-manager.
+type TLSFiles struct {
+	CAFile   string // optional, the system pool is used otherwise
+	CertFile string // optional client certificate
+	KeyFile  string // key of the client certificate
+
+	// Base is cloned for every connection attempt, if set.
+	Base *tls.Config
+	// WatchInterval enables polling the files for changes. Once they
+	// change, the connection is re-established with the new certificates.
+	WatchInterval time.Duration
+}
+
+// WithTLSFiles loads the TLS configuration for amqps:// URIs from files
+// before every connection attempt.
+func WithTLSFiles(files TLSFiles) Option {
+	return func(o *options) {
+		o.tls = &tlsSource{get: files.load, files: &files}
+	}
+}
+
+// WithGetTLSConfig calls get before every connection attempt to obtain the
+// TLS configuration for amqps:// URIs.
+func WithGetTLSConfig(get func() (*tls.Config, error)) Option {
+	return func(o *options) {
+		o.tls = &tlsSource{get: get}
+	}
+}
+
+func (f TLSFiles) load() (*tls.Config, error) {
+	config := &tls.Config{}
+	if f.Base != nil {
+		config = f.Base.Clone()
+	}
+
+	if f.CAFile != "" {
+		pem, err := os.ReadFile(f.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", f.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if f.CertFile != "" || f.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func (f TLSFiles) paths() []string {
+	var paths []string
+	for _, path := range []string{f.CAFile, f.CertFile, f.KeyFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+type tlsSource struct {
+	get   func() (*tls.Config, error)
+	files *TLSFiles
+
+	mu       sync.Mutex
+	last     *tls.Config
+	modTimes map[string]time.Time
+}
+
+func (s *tlsSource) config() (*tls.Config, error) {
+	modTimes := s.statFiles()
+	config, err := s.get()
+	if err != nil {
+		return nil, fmt.Errorf("chamqp: loading TLS config: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last = config
+	s.modTimes = modTimes
+	return config, nil
+}
+
+func (s *tlsSource) lastConfig() *tls.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last
+}
+
+func (s *tlsSource) statFiles() map[string]time.Time {
+	if s.files == nil {
+		return nil
+	}
+	modTimes := map[string]time.Time{}
+	for _, path := range s.files.paths() {
+		info, err := os.Stat(path)
+		if err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// changed reports whether the files were modified since they were loaded.
+func (s *tlsSource) changed() bool {
+	current := s.statFiles()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.modTimes == nil {
+		return false
+	}
+	for path, modTime := range current {
+		if !modTime.Equal(s.modTimes[path]) {
+			return true
+		}
+	}
+	return len(current) != len(s.modTimes)
+}
+
+// tlsWatchInterval is how often the TLS files are checked for changes, or 0
+// without WithTLSFiles and a WatchInterval.
+func (c *Connection) tlsWatchInterval() time.Duration {
+	if c.opts.tls == nil || c.opts.tls.files == nil {
+		return 0
+	}
+	return c.opts.tls.files.WatchInterval
+}
+
+// ConnectionState returns basic TLS details of the current connection, or
+// the zero value while disconnected.
+func (c *Connection) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return tls.ConnectionState{}
+	}
+	return c.conn.ConnectionState()
+}
+
+var errNoClientCertificate = errors.New("chamqp: no client certificate in use")
+
+// ClientCertificate returns the client certificate presented by the current
+// connection.
+func (c *Connection) ClientCertificate() (*x509.Certificate, error) {
+	c.mu.Lock()
+	config := c.tlsConfig
+	c.mu.Unlock()
+
+	if config == nil || len(config.Certificates) == 0 || len(config.Certificates[0].Certificate) == 0 {
+		return nil, errNoClientCertificate
+	}
+	cert := config.Certificates[0]
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
@@ -0,0 +1,140 @@
+package chamqp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCertificate(t *testing.T, dir, name string) TLSFiles {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	files := TLSFiles{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	assert.NoError(t, os.WriteFile(files.CAFile, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(files.CertFile, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return files
+}
+
+func TestTLSFiles(t *testing.T) {
+	t.Run("loads CA and client certificate", func(t *testing.T) {
+		files := writeCertificate(t, t.TempDir(), "client")
+		files.Base = &tls.Config{ServerName: "rabbitmq"}
+
+		config, err := files.load()
+		assert.NoError(t, err)
+		assert.Equal(t, "rabbitmq", config.ServerName)
+		assert.NotNil(t, config.RootCAs)
+		assert.Len(t, config.Certificates, 1)
+		assert.Empty(t, files.Base.Certificates)
+	})
+
+	t.Run("invalid CA file", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("garbage"), 0o600))
+
+		_, err := TLSFiles{CAFile: filepath.Join(dir, "ca.pem")}.load()
+		assert.ErrorContains(t, err, "no certificates found")
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := TLSFiles{CertFile: "/does/not/exist", KeyFile: "/does/not/exist"}.load()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestTLSSource(t *testing.T) {
+	t.Run("detects rotated files", func(t *testing.T) {
+		dir := t.TempDir()
+		files := writeCertificate(t, dir, "first")
+		source := &tlsSource{get: files.load, files: &files}
+		assert.False(t, source.changed())
+
+		_, err := source.config()
+		assert.NoError(t, err)
+		assert.False(t, source.changed())
+
+		writeCertificate(t, dir, "second")
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(files.CertFile, later, later))
+		assert.True(t, source.changed())
+
+		config, err := source.config()
+		assert.NoError(t, err)
+		assert.False(t, source.changed())
+		assert.Same(t, config, source.lastConfig())
+	})
+
+	t.Run("callback errors are wrapped", func(t *testing.T) {
+		source := &tlsSource{get: func() (*tls.Config, error) { return nil, os.ErrNotExist }}
+		_, err := source.config()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, "chamqp: loading TLS config")
+	})
+
+	t.Run("no watch without interval", func(t *testing.T) {
+		assert.Zero(t, newConnection([]Option{WithTLSFiles(TLSFiles{})}).tlsWatchInterval())
+		assert.Zero(t, newConnection([]Option{WithGetTLSConfig(nil)}).tlsWatchInterval())
+		assert.Nil(t, newConnection([]Option{WithTLSFiles(TLSFiles{WatchInterval: time.Second}), WithTLSConfig(nil)}).opts.tls)
+	})
+}
+
+func TestClientCertificate(t *testing.T) {
+	t.Run("certificate of the current connection", func(t *testing.T) {
+		config, err := writeCertificate(t, t.TempDir(), "client").load()
+		assert.NoError(t, err)
+
+		conn := newConnection(nil)
+		_, err = conn.ClientCertificate()
+		assert.ErrorIs(t, err, errNoClientCertificate)
+		assert.Equal(t, tls.ConnectionState{}, conn.ConnectionState())
+
+		conn.tlsConfig = config
+		cert, err := conn.ClientCertificate()
+		assert.NoError(t, err)
+		assert.Equal(t, "client", cert.Subject.CommonName)
+	})
+	t.Run("static TLS config", func(t *testing.T) {
+		config, err := writeCertificate(t, t.TempDir(), "static").load()
+		assert.NoError(t, err)
+
+		conn := DialTLS(newFakeBroker(t).url(), config)
+		defer conn.Close()
+		waitReady(t, conn)
+		cert, err := conn.ClientCertificate()
+		assert.NoError(t, err)
+		assert.Equal(t, "static", cert.Subject.CommonName)
+	})
+}
@@ -0,0 +1,111 @@
+package chamqp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Token is a short-lived secret, for example a JWT for RabbitMQ's OAuth2
+// backend.
+type Token struct {
+	Value  string
+	Expiry time.Time // zero means the token does not expire
+}
+
+// TokenSource supplies tokens. It is called on every connection attempt and
+// before the current token expires.
+type TokenSource interface {
+	Token() (Token, error)
+}
+
+// TokenSourceFunc adapts a function to TokenSource.
+type TokenSourceFunc func() (Token, error)
+
+// Token implements TokenSource.
+func (f TokenSourceFunc) Token() (Token, error) {
+	return f()
+}
+
+// WithTokenSource authenticates with a token from source as password. Before
+// the token expires a new one is sent with connection.update-secret; if that
+// fails, the error is reported to NotifyError receivers and the connection is
+// re-established with a fresh token. It replaces WithCredentialsProvider.
+func WithTokenSource(source TokenSource) Option {
+	return func(o *options) {
+		tokens := &tokenCredentials{source: source}
+		o.credentials = tokens
+		o.tokens = tokens
+	}
+}
+
+type tokenCredentials struct {
+	source TokenSource
+	mu     sync.Mutex
+	expiry time.Time
+}
+
+// Credentials implements CredentialsProvider.
+func (t *tokenCredentials) Credentials() (Credentials, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		return Credentials{}, err
+	}
+	t.setExpiry(token.Expiry)
+	return Credentials{Password: token.Value}, nil
+}
+
+func (t *tokenCredentials) setExpiry(expiry time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expiry = expiry
+}
+
+// refreshIn returns when to refresh the token: one minute before it
+// expires, or halfway through for tokens living less than two minutes.
+func (t *tokenCredentials) refreshIn(now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.expiry.IsZero() {
+		return 0, false
+	}
+	lifetime := t.expiry.Sub(now)
+	margin := time.Minute
+	if lifetime < 2*margin {
+		margin = lifetime / 2
+	}
+	return lifetime - margin, true
+}
+
+// tokenRefreshIn returns when the token of the current connection is due. It
+// returns false without a TokenSource.
+func (c *Connection) tokenRefreshIn() (time.Duration, bool) {
+	if c.opts.tokens == nil {
+		return 0, false
+	}
+	return c.opts.tokens.refreshIn(time.Now())
+}
+
+func (c *Connection) refreshToken() error {
+	token, err := c.opts.tokens.source.Token()
+	if err != nil {
+		return fmt.Errorf("chamqp: refreshing token: %w", err)
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+
+	err = conn.UpdateSecret(token.Value, "token refresh")
+	if err != nil {
+		return fmt.Errorf("chamqp: updating secret: %w", err)
+	}
+	c.opts.tokens.setExpiry(token.Expiry)
+	c.opts.logger.Debug("token refreshed", "expiry", token.Expiry)
+	return nil
+}
@@ -0,0 +1,109 @@
+package chamqp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCredentials(t *testing.T) {
+	now := time.Now()
+
+	t.Run("the token is used as password", func(t *testing.T) {
+		tokens := &tokenCredentials{source: TokenSourceFunc(func() (Token, error) {
+			return Token{"jwt", now.Add(time.Hour)}, nil
+		})}
+
+		credentials, err := tokens.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, Credentials{Password: "jwt"}, credentials)
+
+		delay, ok := tokens.refreshIn(now)
+		assert.True(t, ok)
+		assert.Equal(t, 59*time.Minute, delay)
+	})
+
+	t.Run("short-lived tokens are refreshed halfway", func(t *testing.T) {
+		tokens := &tokenCredentials{expiry: now.Add(time.Minute)}
+		delay, ok := tokens.refreshIn(now)
+		assert.True(t, ok)
+		assert.Equal(t, 30*time.Second, delay)
+	})
+
+	t.Run("tokens without expiry are not refreshed", func(t *testing.T) {
+		_, ok := (&tokenCredentials{}).refreshIn(now)
+		assert.False(t, ok)
+		_, ok = newConnection([]Option{WithTokenSource(TokenSourceFunc(nil))}).tokenRefreshIn()
+		assert.False(t, ok)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Run("source errors are reported", func(t *testing.T) {
+		conn := newConnection([]Option{WithTokenSource(TokenSourceFunc(func() (Token, error) {
+			return Token{}, errors.New("identity provider down")
+		}))})
+
+		assert.EqualError(t, conn.refreshToken(), "chamqp: refreshing token: identity provider down")
+	})
+
+	t.Run("credentials provider replaces the token source", func(t *testing.T) {
+		conn := newConnection([]Option{
+			WithTokenSource(TokenSourceFunc(nil)),
+			WithCredentialsProvider(EnvCredentials{}),
+		})
+		assert.Nil(t, conn.opts.tokens)
+		_, ok := conn.tokenRefreshIn()
+		assert.False(t, ok)
+	})
+}
+
+func TestTokenRefresh(t *testing.T) {
+	t.Run("the connection is updated with fresh tokens", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		var calls atomic.Int64
+		conn := Dial(broker.url(), WithTokenSource(TokenSourceFunc(func() (Token, error) {
+			return Token{fmt.Sprintf("jwt-%d", calls.Add(1)), time.Now().Add(100 * time.Millisecond)}, nil
+		})))
+		defer conn.Close()
+		waitReady(t, conn)
+
+		assert.Eventually(t, func() bool { return len(broker.updatedSecrets()) >= 2 }, time.Second, time.Millisecond)
+		assert.Equal(t, []string{"jwt-2", "jwt-3"}, broker.updatedSecrets()[:2])
+		assert.Zero(t, conn.Status().Reconnects)
+		assert.Zero(t, broker.closes.Load())
+	})
+
+	t.Run("a failed refresh reconnects and is reported", func(t *testing.T) {
+		broker := newFakeBroker(t)
+		var calls atomic.Int64
+		conn := Dial(broker.url(), WithTokenSource(TokenSourceFunc(func() (Token, error) {
+			switch calls.Add(1) {
+			case 1:
+				return Token{"jwt", time.Now().Add(100 * time.Millisecond)}, nil
+			case 2:
+				return Token{}, errors.New("identity provider down")
+			}
+			return Token{"jwt", time.Now().Add(time.Hour)}, nil
+		})), WithReconnectPolicy(ConstantBackoff{Interval: time.Millisecond}))
+		defer conn.Close()
+		errs := conn.NotifyError(make(chan error, 4))
+		waitReady(t, conn)
+
+		select {
+		case err := <-errs:
+			assert.EqualError(t, err, "chamqp: refreshing token: identity provider down")
+		case <-time.After(time.Second):
+			t.Fatal("failed refresh not reported")
+		}
+		assert.Eventually(t, func() bool {
+			return conn.Status().Reconnects == 1 && conn.State() == StateTopologyApplied
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, int64(3), calls.Load())
+		assert.Equal(t, int64(1), broker.closes.Load())
+	})
+}
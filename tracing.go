@@ -0,0 +1,140 @@
+package chamqp
+
+import (
+	"context"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// Propagator moves trace context between a context.Context and message
+// headers. Use HeadersCarrier to adapt tracer specific propagators, for
+// example OpenTelemetry's TextMapPropagator.
+type Propagator interface {
+	// Inject writes the trace context of ctx into headers.
+	Inject(ctx context.Context, headers amqp.Table)
+	// Extract returns a copy of ctx carrying the trace context found in
+	// headers.
+	Extract(ctx context.Context, headers amqp.Table) context.Context
+}
+
+// WithPropagator replaces the W3CPropagator used to inject trace context on
+// publish and extract it on consume.
+func WithPropagator(propagator Propagator) Option {
+	return func(o *options) {
+		o.propagator = propagator
+	}
+}
+
+// Trace holds the values of the W3C traceparent and tracestate headers.
+type Trace struct {
+	Parent string
+	State  string
+}
+
+type traceKey struct{}
+
+// ContextWithTrace returns a copy of ctx carrying trace.
+func ContextWithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext returns the trace stored by ContextWithTrace.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	trace, ok := ctx.Value(traceKey{}).(Trace)
+	return trace, ok
+}
+
+// W3CPropagator propagates the Trace stored in a context as W3C traceparent
+// and tracestate headers. It works without any tracing library.
+type W3CPropagator struct{}
+
+// Inject implements Propagator.
+func (W3CPropagator) Inject(ctx context.Context, headers amqp.Table) {
+	trace, ok := TraceFromContext(ctx)
+	if !ok || !validTraceParent(trace.Parent) {
+		return
+	}
+	headers[traceparentHeader] = trace.Parent
+	if trace.State != "" {
+		headers[tracestateHeader] = trace.State
+	}
+}
+
+// Extract implements Propagator.
+func (W3CPropagator) Extract(ctx context.Context, headers amqp.Table) context.Context {
+	parent, _ := headers[traceparentHeader].(string)
+	if !validTraceParent(parent) {
+		return ctx
+	}
+	state, _ := headers[tracestateHeader].(string)
+	return ContextWithTrace(ctx, Trace{parent, state})
+}
+
+// validTraceParent checks the version-traceid-parentid-flags layout.
+func validTraceParent(parent string) bool {
+	parts := strings.Split(parent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false
+	}
+	for _, part := range parts[:4] {
+		for _, r := range part {
+			if !strings.ContainsRune("0123456789abcdef", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// HeadersCarrier adapts amqp.Table to the Get/Set/Keys carrier interface
+// used by tracing libraries.
+type HeadersCarrier amqp.Table
+
+// Get returns the string value of key.
+func (c HeadersCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+// Set stores value under key.
+func (c HeadersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys lists all header names.
+func (c HeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Delivery is an amqp.Delivery together with the context extracted from its
+// headers.
+type Delivery struct {
+	amqp.Delivery
+	Context context.Context
+}
+
+// injectHeaders returns headers extended by the trace context of ctx. The
+// given table is never modified, since callers often reuse it.
+func injectHeaders(ctx context.Context, propagator Propagator, headers amqp.Table) amqp.Table {
+	injected := amqp.Table{}
+	propagator.Inject(ctx, injected)
+	if len(injected) == 0 {
+		return headers
+	}
+	for key, value := range headers {
+		if _, ok := injected[key]; !ok {
+			injected[key] = value
+		}
+	}
+	return injected
+}
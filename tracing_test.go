@@ -0,0 +1,62 @@
+package chamqp
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestW3CPropagator(t *testing.T) {
+	t.Run("inject and extract round trip", func(t *testing.T) {
+		ctx := ContextWithTrace(context.Background(), Trace{testTraceParent, "vendor=value"})
+		headers := amqp.Table{}
+		W3CPropagator{}.Inject(ctx, headers)
+		assert.Equal(t, amqp.Table{"traceparent": testTraceParent, "tracestate": "vendor=value"}, headers)
+
+		trace, ok := TraceFromContext(W3CPropagator{}.Extract(context.Background(), headers))
+		assert.True(t, ok)
+		assert.Equal(t, Trace{testTraceParent, "vendor=value"}, trace)
+	})
+
+	t.Run("invalid traceparent is ignored", func(t *testing.T) {
+		ctx := W3CPropagator{}.Extract(context.Background(), amqp.Table{"traceparent": "garbage"})
+		_, ok := TraceFromContext(ctx)
+		assert.False(t, ok)
+	})
+}
+
+func TestInjectHeaders(t *testing.T) {
+	t.Run("does not modify the given headers", func(t *testing.T) {
+		ctx := ContextWithTrace(context.Background(), Trace{Parent: testTraceParent})
+		headers := amqp.Table{"x-custom": "value"}
+
+		injected := injectHeaders(ctx, W3CPropagator{}, headers)
+
+		assert.Equal(t, amqp.Table{"x-custom": "value"}, headers)
+		assert.Equal(t, amqp.Table{"x-custom": "value", "traceparent": testTraceParent}, injected)
+	})
+
+	t.Run("keeps nil headers without trace", func(t *testing.T) {
+		assert.Nil(t, injectHeaders(context.Background(), W3CPropagator{}, nil))
+	})
+}
+
+func TestShovelWithContext(t *testing.T) {
+	t.Run("attaches the extracted context", func(t *testing.T) {
+		src := make(chan amqp.Delivery, 1)
+		dest := make(chan Delivery, 1)
+		src <- amqp.Delivery{Headers: amqp.Table{"traceparent": testTraceParent}}
+		close(src)
+
+		shovelWithContext(src, dest, nil, W3CPropagator{}, nopMetrics{}, nil)
+
+		delivery := <-dest
+		trace, ok := TraceFromContext(delivery.Context)
+		assert.True(t, ok)
+		assert.Equal(t, testTraceParent, trace.Parent)
+	})
+}